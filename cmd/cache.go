@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// cacheVersion is the version of the -cache file format. Caches of other
+// versions are discarded.
+const cacheVersion = 1
+
+// Cache holds the content of the input files read by a previous run, so that
+// unchanged files are not read again. It only holds file contents, which do
+// not depend on the flags: the snippets are always generated again from them
+// with the current flags.
+type Cache struct {
+	Version int                   `json:"version"`
+	Files   map[string]cacheEntry `json:"files"`
+}
+
+// cacheEntry is the content of an input file as of its modification time and
+// size.
+type cacheEntry struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Content []byte    `json:"content"`
+}
+
+// loadCache reads the cache in fileName. A missing file gives an empty cache
+// and an unreadable one is reported and discarded.
+func loadCache(fileName string) *Cache {
+	cache := &Cache{Version: cacheVersion, Files: map[string]cacheEntry{}}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache
+	}
+	var loaded Cache
+	if err == nil {
+		err = json.Unmarshal(b, &loaded)
+	}
+	if err != nil {
+		warnf("discarding cache %s: %v", fileName, err)
+		return cache
+	}
+	if loaded.Version != cacheVersion || loaded.Files == nil {
+		verbosef("discarding cache %s: version %d", fileName, loaded.Version)
+		return cache
+	}
+	return &loaded
+}
+
+// fill stats the sources not read yet and sets the content of the ones
+// unchanged since they were cached.
+func (c *Cache) fill(sources []source) {
+	for i := range sources {
+		src := &sources[i]
+		if src.read {
+			continue
+		}
+		info, err := os.Stat(src.pathName)
+		if err != nil {
+			continue
+		}
+		src.modTime, src.size = info.ModTime(), info.Size()
+		if entry, ok := c.Files[src.pathName]; ok && entry.ModTime.Equal(src.modTime) && entry.Size == src.size {
+			src.content, src.read = entry.Content, true
+			verbosef("using cached %s", src.pathName)
+		}
+	}
+}
+
+// update replaces the cached files with the stated sources that became
+// snippets: the ones in skipped, left out as too large, binary or filtered
+// out by their content, are not cached.
+func (c *Cache) update(sources []source, skipped []skippedFile) {
+	left := make(map[string]bool, len(skipped))
+	for _, s := range skipped {
+		left[s.Path] = true
+	}
+	c.Files = make(map[string]cacheEntry, len(sources))
+	for _, src := range sources {
+		if src.modTime.IsZero() || left[src.pathName] {
+			continue
+		}
+		c.Files[src.pathName] = cacheEntry{ModTime: src.modTime, Size: src.size, Content: src.content}
+	}
+}
+
+// save writes c into fileName.
+func (c *Cache) save(fileName string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", fileName, err)
+	}
+	if err := os.WriteFile(fileName, b, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", fileName, err)
+	}
+	return nil
+}
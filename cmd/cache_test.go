@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":  "for {}\n",
+		"src/retry.go": "retry()\n",
+	})
+	src, out, cache := filepath.Join(dir, "src"), filepath.Join(dir, "out"), filepath.Join(dir, "cache.json")
+	logged := func(flags ...string) string {
+		args, err := setup(t, append([]string{"-o", out, "-cache", cache, "-v", src}, flags...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		LogOutput = &buf
+		if err := process(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	body := func(key string) string {
+		return strings.Join(readSnippets(t, filepath.Join(out, "go.json"))[key].Body, "\n")
+	}
+
+	if got := logged(); strings.Contains(got, "using cached") {
+		t.Errorf("got %q logged without a cache", got)
+	}
+	if c := loadCache(cache); len(c.Files) != 2 {
+		t.Fatalf("got %d cached files, want 2", len(c.Files))
+	}
+
+	// Unchanged files are neither read nor written again.
+	got := logged()
+	for _, want := range []string{"using cached " + filepath.Join(src, "loop.go"), "using cached " + filepath.Join(src, "retry.go"), "is up to date"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, want %q logged", got, want)
+		}
+	}
+
+	// A file with the same size and modification time is taken as
+	// unchanged.
+	loop := filepath.Join(src, "loop.go")
+	info, err := os.Stat(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, src, map[string]string{"loop.go": "for {{\n"})
+	if err := os.Chtimes(loop, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	logged()
+	if got := body("loop"); got != "for {}" {
+		t.Errorf("got body %q, want the cached one", got)
+	}
+
+	// Changed files are read, and their snippet file rewritten.
+	writeFiles(t, src, map[string]string{"loop.go": "for i := 0; ; i++ {}\n"})
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(loop, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got = logged()
+	if strings.Contains(got, "using cached "+loop) || strings.Contains(got, "is up to date") {
+		t.Errorf("got %q logged for a changed file", got)
+	}
+	if got := body("loop"); got != "for i := 0; ; i++ {}" {
+		t.Errorf("got body %q, want the changed one", got)
+	}
+
+	// Flags affecting the output apply to the cached files.
+	logged("-prefix", "go-{name}")
+	if got := readSnippets(t, filepath.Join(out, "go.json"))["retry"].Prefix; len(got) != 1 || got[0] != "go-retry" {
+		t.Errorf("got prefix %q, want %q", got, "go-retry")
+	}
+
+	// An unreadable cache is discarded.
+	writeFiles(t, dir, map[string]string{"cache.json": "not json"})
+	if got := logged(); !strings.Contains(got, "discarding cache") {
+		t.Errorf("got %q, want the cache discarded", got)
+	}
+}
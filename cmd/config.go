@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// ConfigFile is the configuration file looked up in the current directory
+// when -config is not given.
+const ConfigFile = ".snippetgenrc"
+
+// Config holds the defaults read from a configuration file.
+type Config struct {
+	Indent  *string           `json:"indent"`
+	Output  *string           `json:"output"`
+	Exclude []string          `json:"exclude"`
+	LangMap map[string]string `json:"langMap"`
+}
+
+// loadConfig reads the JSON configuration in fileName. A missing file is
+// only an error if required is set.
+func loadConfig(fileName string, required bool) (*Config, error) {
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", fileName, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", fileName, err)
+	}
+	return &cfg, nil
+}
+
+// apply sets the flags not given on the command line, nor by the
+// environment, from cfg.
+func (cfg *Config) apply() error {
+	set := func(name, value string) error {
+		if isSet(name) {
+			return nil
+		}
+		return flag.Set(name, value)
+	}
+	if cfg.Indent != nil {
+		if err := set("i", *cfg.Indent); err != nil {
+			return err
+		}
+	}
+	if cfg.Output != nil {
+		if err := set("o", *cfg.Output); err != nil {
+			return err
+		}
+	}
+	if !isSet("exclude") {
+		for _, pattern := range cfg.Exclude {
+			if err := flag.Set("exclude", pattern); err != nil {
+				return err
+			}
+		}
+	}
+	for ext, lang := range cfg.LangMap {
+		if _, ok := LangMap[ext]; !ok {
+			LangMap[ext] = lang
+		}
+	}
+	return nil
+}
+
+// EnvFlags maps the environment variables supplying flag defaults to their
+// flags.
+var EnvFlags = map[string]string{
+	"SNIPPETGEN_OUTPUT":  "o",
+	"SNIPPETGEN_INDENT":  "i",
+	"SNIPPETGEN_EXCLUDE": "exclude",
+}
+
+// applyEnv sets the flags not given on the command line from the EnvFlags
+// environment variables.
+func applyEnv() error {
+	for env, name := range EnvFlags {
+		value, ok := os.LookupEnv(env)
+		if !ok || isSet(name) {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+	}
+	return nil
+}
+
+// applyConfig applies the -config file, or ConfigFile if present.
+func applyConfig() error {
+	cfg, err := loadConfig(ConfigPath, isSet("config"))
+	if err != nil {
+		return err
+	}
+	return cfg.apply()
+}
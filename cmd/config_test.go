@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"good.json": `{"indent": "2", "output": "out", "exclude": ["*.tmp"], "langMap": {"tmpl": "go"}}`,
+		"bad.json":  `{"indent": 2}`,
+	})
+	out, indent := "out", "2"
+	for _, tt := range []struct {
+		name     string
+		file     string
+		required bool
+		want     *Config
+		wantErr  bool
+	}{
+		{"good", "good.json", false, &Config{Indent: &indent, Output: &out, Exclude: []string{"*.tmp"}, LangMap: map[string]string{"tmpl": "go"}}, false},
+		{"missing", "missing.json", false, &Config{}, false},
+		{"missing required", "missing.json", true, nil, true},
+		{"bad", "bad.json", false, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadConfig(filepath.Join(dir, tt.file), tt.required)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":   "for {}\n",
+		"src/page.tmpl": "{{.}}\n",
+		"src/skip.tmp":  "tmp\n",
+		"src/.snippetgenrc": `{
+			"indent": "2",
+			"output": "` + filepath.ToSlash(filepath.Join(dir, "config-out")) + `",
+			"exclude": ["*.tmp"],
+			"langMap": {"tmpl": "go"}
+		}`,
+	})
+	chdir(t, filepath.Join(dir, "src"))
+
+	// The configuration in the current directory applies.
+	mustRun(t, ".")
+	fileName := filepath.Join(dir, "config-out", "go.json")
+	if got := keys(t, fileName); !reflect.DeepEqual(got, []string{"loop", "page"}) {
+		t.Errorf("go.json: got %q", got)
+	}
+	if b, _ := os.ReadFile(fileName); !strings.HasPrefix(string(b), "{\n  \"") {
+		t.Errorf("got %q, want it indented with the configured indent", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config-out", "tmp.json")); !os.IsNotExist(err) {
+		t.Errorf("configured exclude ignored: %v", err)
+	}
+
+	// Flags override it.
+	out := filepath.Join(dir, "flag-out")
+	mustRun(t, "-o", out, "-i", "4", "-lang-map", "tmpl=html", "-exclude", "*.go", ".")
+	if got := keys(t, filepath.Join(out, "html.json")); !reflect.DeepEqual(got, []string{"page"}) {
+		t.Errorf("html.json: got %q", got)
+	}
+	if got := keys(t, filepath.Join(out, "tmp.json")); !reflect.DeepEqual(got, []string{"skip"}) {
+		t.Errorf("tmp.json: got %q", got)
+	}
+	if b, _ := os.ReadFile(filepath.Join(out, "html.json")); !strings.HasPrefix(string(b), "{\n    \"") {
+		t.Errorf("got %q, want it indented with -i", b)
+	}
+
+	// An explicit -config must exist.
+	if err := run(t, "-config", filepath.Join(dir, "missing.json"), "."); err == nil {
+		t.Error("got no error for a missing -config")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":  "for {}\n",
+		"src/skip.tmp": "tmp\n",
+	})
+	envOut := filepath.Join(dir, "env-out")
+	t.Setenv("SNIPPETGEN_OUTPUT", envOut)
+	t.Setenv("SNIPPETGEN_INDENT", "1")
+	t.Setenv("SNIPPETGEN_EXCLUDE", "*.tmp")
+
+	mustRun(t, filepath.Join(dir, "src"))
+	b, err := os.ReadFile(filepath.Join(envOut, "go.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "{\n \"loop\"") {
+		t.Errorf("got %q, want it indented with SNIPPETGEN_INDENT", b)
+	}
+	if _, err := os.Stat(filepath.Join(envOut, "tmp.json")); !os.IsNotExist(err) {
+		t.Errorf("SNIPPETGEN_EXCLUDE ignored: %v", err)
+	}
+
+	// Flags take precedence over the environment.
+	flagOut := filepath.Join(dir, "flag-out")
+	mustRun(t, "-o", flagOut, "-exclude", "*.go", filepath.Join(dir, "src"))
+	if got := keys(t, filepath.Join(flagOut, "tmp.json")); !reflect.DeepEqual(got, []string{"skip"}) {
+		t.Errorf("tmp.json: got %q", got)
+	}
+
+	// The environment takes precedence over the configuration file.
+	writeFiles(t, dir, map[string]string{"rc.json": `{"output": "` + filepath.ToSlash(filepath.Join(dir, "rc-out")) + `"}`})
+	mustRun(t, "-config", filepath.Join(dir, "rc.json"), filepath.Join(dir, "src"))
+	if _, err := os.Stat(filepath.Join(dir, "rc-out")); !os.IsNotExist(err) {
+		t.Errorf("configuration file took precedence over SNIPPETGEN_OUTPUT: %v", err)
+	}
+
+	t.Setenv("SNIPPETGEN_INDENT", "x")
+	if err := run(t, filepath.Join(dir, "src")); err == nil {
+		t.Error("got no error for an invalid SNIPPETGEN_INDENT")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hasMeta reports whether pathName contains glob metacharacters.
+func hasMeta(pathName string) bool {
+	return strings.ContainsAny(pathName, "*?[")
+}
+
+// glob returns the files matching pattern that are not excluded, by the
+// patterns of ignoresFor or by the SnippetignoreFile files of the directories
+// walked. Besides the path.Match syntax, a "**" element matches any number of
+// directories.
+func glob(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("pattern %s: %w", pattern, err)
+		}
+	}
+
+	i := 0
+	for i < len(segments) && !hasMeta(segments[i]) {
+		i++
+	}
+	root := filepath.FromSlash(strings.Join(segments[:i], "/"))
+	switch {
+	case root == "" && i > 0:
+		root = string(filepath.Separator)
+	case root == "":
+		root = "."
+	}
+
+	ignores, err := ignoresFor(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	if err := walk(root, func(pathName string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return walkError(pathName, err)
+		}
+		if skip, err := ignores.visit(pathName, info); skip || err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, pathName)
+		if err != nil {
+			return err
+		}
+		if matchSegments(segments[i:], strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, pathName)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking %s: %w", root, err)
+	}
+	return matches, nil
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for j := 0; j <= len(name); j++ {
+			if matchSegments(pattern[1:], name[j:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// expandArgs replaces the glob patterns in args with the files they match.
+// Other arguments are kept as is.
+func expandArgs(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		if arg == "-" || !hasMeta(arg) {
+			expanded = append(expanded, arg)
+			continue
+		}
+		matches, err := glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %s: no matching files", arg)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// globFiles is the tree the glob tests run in.
+var globFiles = map[string]string{
+	"main.go":           "package main\n",
+	"util.go":           "package main\n",
+	"web/app.ts":        "export {}\n",
+	"web/lib/deep.ts":   "export {}\n",
+	"web/lib/other.js":  "export {}\n",
+	"templates/loop.go": "for {}\n",
+}
+
+func TestExpandArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"star", []string{"*.go"}, []string{"main.go", "util.go"}, false},
+		{"double star", []string{"**/*.ts"}, []string{"web/app.ts", "web/lib/deep.ts"}, false},
+		{"double star below", []string{"web/**/*.ts"}, []string{"web/app.ts", "web/lib/deep.ts"}, false},
+		{"literal directory", []string{"templates"}, []string{"templates"}, false},
+		{"stdin", []string{"-"}, []string{"-"}, false},
+		{"class", []string{"web/lib/[do]*"}, []string{"web/lib/deep.ts", "web/lib/other.js"}, false},
+		{"no match", []string{"*.rs"}, nil, true},
+		{"bad pattern", []string{"[.go"}, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, globFiles)
+			chdir(t, dir)
+			got, err := expandArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			for i := range tt.want {
+				tt.want[i] = filepath.FromSlash(tt.want[i])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name []string
+		want          bool
+	}{
+		{[]string{"*.go"}, []string{"a.go"}, true},
+		{[]string{"*.go"}, []string{"d", "a.go"}, false},
+		{[]string{"**", "*.go"}, []string{"a.go"}, true},
+		{[]string{"**", "*.go"}, []string{"d", "e", "a.go"}, true},
+		{[]string{"d", "**"}, []string{"d", "e", "a.go"}, true},
+		{[]string{"d", "**", "a.go"}, []string{"x", "a.go"}, false},
+	} {
+		if got := matchSegments(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchSegments(%q, %q): got %t, want %t", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
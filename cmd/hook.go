@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// HookDirEnv is the environment variable holding the output directory
+// in the -post-hook command.
+const HookDirEnv = "SNIPPETGEN_OUTPUT_DIR"
+
+// runHook runs the -post-hook command, if any, with the shell after the
+// snippets were written to dir. Its output goes to ours.
+func runHook(ctx context.Context, dir string) error {
+	if PostHook == "" {
+		return nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", PostHook)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", PostHook)
+	}
+	cmd.Env = append(os.Environ(), HookDirEnv+"="+dir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	verbosef("running %s", PostHook)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-hook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPostHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks use sh")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+	out := filepath.Join(dir, "out")
+
+	mustRun(t, "-o", out, "-post-hook", `touch "$`+HookDirEnv+`/ran"`, filepath.Join(dir, "loop.go"))
+	if _, err := os.Stat(filepath.Join(out, "ran")); err != nil {
+		t.Errorf("hook did not run in the output directory: %v", err)
+	}
+
+	err := run(t, "-o", out, "-post-hook", "exit 3", filepath.Join(dir, "loop.go"))
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+
+	os.Remove(filepath.Join(out, "ran"))
+	captureStdout(t, func() {
+		mustRun(t, "-o", out, "-dry-run", "-post-hook", `touch "$`+HookDirEnv+`/ran"`, filepath.Join(dir, "loop.go"))
+	})
+	if _, err := os.Stat(filepath.Join(out, "ran")); !os.IsNotExist(err) {
+		t.Errorf("hook ran without writing: %v", err)
+	}
+}
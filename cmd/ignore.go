@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignorePattern is a single gitignore-style pattern.
+type ignorePattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+func (p *ignorePattern) match(segments []string) bool {
+	if !p.anchored {
+		segments = segments[len(segments)-1:]
+	}
+	return matchSegments(p.segments, segments)
+}
+
+// Ignore is a list of gitignore-style patterns relative to a directory.
+type Ignore struct {
+	dir      string
+	patterns []ignorePattern
+}
+
+// NewIgnore returns the patterns in lines, relative to dir. Blank lines and
+// lines starting with # are skipped.
+func NewIgnore(dir string, lines []string) (*Ignore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	ig := &Ignore{dir: abs}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		p.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		p.segments = strings.Split(line, "/")
+		ig.patterns = append(ig.patterns, p)
+	}
+	return ig, nil
+}
+
+// ReadIgnore returns the patterns in the file fileName, relative to the
+// directory containing it.
+func ReadIgnore(fileName string) (*Ignore, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", fileName, err)
+	}
+	return NewIgnore(filepath.Dir(fileName), lines)
+}
+
+// Excludes reports whether pathName, or any directory between ig's
+// directory and pathName, is excluded by the patterns. The last matching
+// pattern wins.
+func (ig *Ignore) Excludes(pathName string, isDir bool) bool {
+	abs, err := filepath.Abs(pathName)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(ig.dir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i <= len(segments); i++ {
+		if ig.excludes(segments[:i], isDir || i < len(segments)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ig *Ignore) excludes(segments []string, isDir bool) bool {
+	excluded := false
+	for i := range ig.patterns {
+		p := &ig.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.match(segments) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// Ignores is a set of pattern lists; a path is excluded if any list
+// excludes it.
+type Ignores []*Ignore
+
+func (igs Ignores) Excludes(pathName string, isDir bool) bool {
+	for _, ig := range igs {
+		if ig.Excludes(pathName, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestGitignore returns the patterns of the .gitignore closest to
+// pathName, looking in pathName and then in every parent directory.
+func nearestGitignore(pathName string) (*Ignore, error) {
+	dir, err := filepath.Abs(pathName)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		ig, err := ReadIgnore(filepath.Join(dir, ".gitignore"))
+		if err == nil {
+			return ig, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// SnippetignoreFile holds, in any directory walked, patterns excluding
+// paths relative to that directory, as .gitignore files do.
+const SnippetignoreFile = ".snippetignore"
+
+// snippetignore returns the patterns of the SnippetignoreFile in dir, or
+// nil if there is none.
+func snippetignore(dir string) (*Ignore, error) {
+	ig, err := ReadIgnore(filepath.Join(dir, SnippetignoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return ig, err
+}
+
+// fileSnippetignores returns the patterns of the SnippetignoreFile files
+// applying to the file pathName when it is given by itself rather than
+// walked: the ones in its directory and in every parent directory up to the
+// current directory, when it is within it.
+func fileSnippetignores(pathName string) (Ignores, error) {
+	dir, err := filepath.Abs(filepath.Dir(pathName))
+	if err != nil {
+		return nil, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(wd, dir)
+	within := err == nil && (rel == "." || filepath.IsLocal(rel))
+
+	var igs Ignores
+	for {
+		ig, err := snippetignore(dir)
+		if err != nil {
+			return nil, err
+		}
+		if ig != nil {
+			igs = append(igs, ig)
+		}
+		parent := filepath.Dir(dir)
+		if !within || dir == wd || parent == dir {
+			return igs, nil
+		}
+		dir = parent
+	}
+}
+
+// visit applies igs to the path visited while walking, with info. It
+// reports whether to skip path, returning filepath.SkipDir for excluded
+// directories, and adds the SnippetignoreFile of the other directories to
+// igs, the patterns only applying under their directory, walked after it.
+// SnippetignoreFile files are skipped too.
+func (igs *Ignores) visit(path string, info fs.FileInfo) (bool, error) {
+	if igs.Excludes(path, info.IsDir()) {
+		if info.IsDir() {
+			return true, filepath.SkipDir
+		}
+		return true, nil
+	}
+	if info.IsDir() {
+		ig, err := snippetignore(path)
+		if err != nil {
+			return true, err
+		}
+		if ig != nil {
+			*igs = append(*igs, ig)
+		}
+		return false, nil
+	}
+	return info.Name() == SnippetignoreFile, nil
+}
+
+// ignoresFor returns the patterns excluding paths while walking root: the
+// -exclude patterns, relative to root, and with -gitignore the nearest
+// .gitignore and the .git directories.
+func ignoresFor(root string) (Ignores, error) {
+	var igs Ignores
+	dir := root
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		dir = filepath.Dir(root)
+	}
+	if len(Exclude) > 0 {
+		ig, err := NewIgnore(dir, Exclude)
+		if err != nil {
+			return nil, err
+		}
+		igs = append(igs, ig)
+	}
+	if Gitignore {
+		// Git never lists its own directory in .gitignore files.
+		git, err := NewIgnore(dir, []string{".git"})
+		if err != nil {
+			return nil, err
+		}
+		igs = append(igs, git)
+		ig, err := nearestGitignore(root)
+		if err != nil {
+			return nil, err
+		}
+		if ig != nil {
+			igs = append(igs, ig)
+		}
+	}
+	return igs, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func TestIgnoreExcludes(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{[]string{"node_modules"}, "node_modules", true, true},
+		{[]string{"node_modules"}, "web/node_modules/x.js", false, true},
+		{[]string{"*.min.js"}, "web/app.min.js", false, true},
+		{[]string{"*.min.js"}, "web/app.js", false, false},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "build", true, true},
+		{[]string{"build/"}, "build/out.go", false, true},
+		{[]string{"/top.go"}, "top.go", false, true},
+		{[]string{"/top.go"}, "sub/top.go", false, false},
+		{[]string{"docs/*.md"}, "docs/a.md", false, true},
+		{[]string{"docs/*.md"}, "web/docs/a.md", false, false},
+		{[]string{"*.go", "!keep.go"}, "keep.go", false, false},
+		{[]string{"*.go", "!keep.go"}, "drop.go", false, true},
+		{[]string{"# comment", "", "drop.go"}, "drop.go", false, true},
+		{[]string{"drop.go"}, "../drop.go", false, false},
+	} {
+		ig, err := NewIgnore(dir, tt.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ig.Excludes(filepath.Join(dir, filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+			t.Errorf("%q excludes %s: got %t, want %t", tt.patterns, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":              "for {}\n",
+		"src/loop_test.go":         "package x\n",
+		"src/node_modules/dep.js":  "module.exports = {}\n",
+		"src/vendor/lib/vendor.go": "package lib\n",
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-exclude", "node_modules,*_test.go", "-exclude", "vendor/", filepath.Join(dir, "src"))
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"loop"}) {
+		t.Errorf("go.json: got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(out, "javascript.json")); !os.IsNotExist(err) {
+		t.Errorf("node_modules not pruned: %v", err)
+	}
+}
+
+func TestGitignoreFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":         "build/\n*.gen.go\n",
+		"src/loop.go":        "for {}\n",
+		"src/loop.gen.go":    "package x\n",
+		"src/build/out.go":   "package build\n",
+		".git/hooks/hook.sh": "exit 0\n",
+		".git/config.go":     "package git\n",
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-gitignore", dir)
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"loop"}) {
+		t.Errorf("go.json: got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(out, "shellscript.json")); !os.IsNotExist(err) {
+		t.Errorf(".git not pruned: %v", err)
+	}
+
+	// Without -gitignore, everything is taken.
+	out = filepath.Join(dir, "all")
+	mustRun(t, "-o", out, filepath.Join(dir, "src"))
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"loop", "loop.gen", "out"}) {
+		t.Errorf("go.json: got %q", got)
+	}
+}
+
+func TestSnippetignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/.snippetignore":         "*.tmp.go\ndrafts/\n",
+		"src/loop.go":                "for {}\n",
+		"src/loop.tmp.go":            "for {}\n",
+		"src/drafts/draft.go":        "package drafts\n",
+		"src/go/.snippetignore":      "/retry.go\n!keep.tmp.go\nlib\n",
+		"src/go/retry.go":            "retry()\n",
+		"src/go/keep.tmp.go":         "keep()\n",
+		"src/go/lib/lib.go":          "package lib\n",
+		"src/go/sub/retry.go":        "retry()\n",
+		"src/python/retry.py":        "retry()\n",
+		"src/python/lib/lib.py":      "pass\n",
+		"src/python/drafts/draft.py": "pass\n",
+		"src/python/drafts/x.tmp.go": "package x\n",
+	})
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, src)
+	for file, want := range map[string][]string{
+		// The root patterns apply all the way down, the nested ones only
+		// under their directory, and anchored to it; the nested negation
+		// cannot bring back a file excluded above.
+		"go.json":     {"loop", "retry"},
+		"python.json": {"lib", "retry"},
+	} {
+		if got := keys(t, filepath.Join(out, file)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", file, got, want)
+		}
+	}
+	if got := readSnippets(t, filepath.Join(out, "go.json"))["retry"].Body; !reflect.DeepEqual(got, snippet.Body{"retry()"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(out, "plaintext.json")); !os.IsNotExist(err) {
+		t.Errorf(".snippetignore files became snippets: %v", err)
+	}
+
+	// Files given by themselves honor the .snippetignore files above them
+	// within the current directory.
+	chdir(t, dir)
+	out = filepath.Join(dir, "files")
+	mustRun(t, "-o", out, filepath.Join("src", "go", "retry.go"), filepath.Join("src", "loop.tmp.go"), filepath.Join("src", "go", "sub", "retry.go"))
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"retry"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSnippetignorePrunes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".snippetignore": "skipped/\n",
+		"skipped/a.go":   "a()\n",
+		"kept/b.go":      "b()\n",
+	})
+	var igs Ignores
+	var visited []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		skip, err := igs.visit(path, info)
+		if !skip {
+			rel, _ := filepath.Rel(dir, path)
+			visited = append(visited, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".", "kept", "kept/b.go"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("got %q, want %q", visited, want)
+	}
+}
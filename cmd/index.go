@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// writeIndex writes into fileName the JSON catalog of snippets.
+func writeIndex(fileName string, snippets *snippet.Snippets) error {
+	index := snippets.Index()
+	if index == nil {
+		index = []snippet.IndexEntry{}
+	}
+	b, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding index: %w", err)
+	}
+	if err := os.WriteFile(fileName, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("%w %s: %w", snippet.ErrWriteFailed, fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func TestIndexFlag(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		flags []string
+		want  []snippet.IndexEntry
+	}{
+		{
+			name: "per language",
+			files: map[string]string{
+				"src/loop.go": "for {}\n",
+				"src/loop.py": "while True: pass\n",
+			},
+			want: []snippet.IndexEntry{
+				{Key: "loop", Prefix: snippet.Prefix{"loop"}, Language: "go", File: "go.json"},
+				{Key: "loop", Prefix: snippet.Prefix{"loop"}, Language: "python", File: "python.json"},
+			},
+		},
+		{
+			name:  "split",
+			files: map[string]string{"src/loop.go": "for {}\n"},
+			flags: []string{"-split"},
+			want: []snippet.IndexEntry{
+				{Key: "loop", Prefix: snippet.Prefix{"loop"}, Language: "go", File: filepath.Join("go", "loop.json")},
+			},
+		},
+		{
+			name:  "no snippets",
+			files: map[string]string{"src/README": "nothing\n"},
+			want:  []snippet.IndexEntry{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			index := filepath.Join(dir, "index.json")
+			args := append([]string{"-o", filepath.Join(dir, "out"), "-index", index}, tt.flags...)
+			mustRun(t, append(args, filepath.Join(dir, "src"))...)
+			b, err := os.ReadFile(index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []snippet.IndexEntry
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
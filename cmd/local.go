@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// localRoot returns the project root of -local for the directory dir: its
+// nearest ancestor, dir included, with a WorkspaceFolder directory, or else
+// the nearest with a .git, or else dir itself.
+func localRoot(dir string) string {
+	if root, ok := findUp(dir, WorkspaceFolder, true); ok {
+		return root
+	}
+	if root, ok := findUp(dir, ".git", false); ok {
+		return root
+	}
+	return dir
+}
+
+// findUp returns the nearest ancestor of dir, dir included, with an entry
+// named name, which must be a directory if isDir is set.
+func findUp(dir, name string, isDir bool) (string, bool) {
+	for {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && (!isDir || info.IsDir()) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
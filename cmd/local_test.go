@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func TestLocalRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"vscode/.vscode/settings.json": "{}\n",
+		"vscode/a/b/keep":              "",
+		"git/.git/HEAD":                "ref: refs/heads/main\n",
+		"git/a/.vscode":                "not a directory\n",
+		"git/a/b/keep":                 "",
+		"both/.git/HEAD":               "ref: refs/heads/main\n",
+		"both/a/.vscode/keep":          "",
+		"both/a/b/keep":                "",
+		"none/a/keep":                  "",
+	})
+	for _, tt := range []struct {
+		dir, want string
+	}{
+		{"vscode/a/b", "vscode"},
+		{"vscode", "vscode"},
+		{"git/a/b", "git"},
+		{"both/a/b", "both/a"},
+		{"none/a", "none/a"},
+	} {
+		got := localRoot(filepath.Join(dir, filepath.FromSlash(tt.dir)))
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("localRoot(%s) = %s, want %s", tt.dir, got, want)
+		}
+	}
+}
+
+func TestLocalFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"repo/.git/HEAD":            "ref: refs/heads/main\n",
+		"repo/project/.vscode/keep": "",
+		"repo/project/src/loop.go":  "for {}\n",
+		"repo/other/src/retry.go":   "retry()\n",
+	})
+
+	// The ancestor .vscode is found from a subdirectory.
+	chdir(t, filepath.Join(dir, "repo", "project", "src"))
+	mustRun(t, "-local", ".")
+	want := snippet.Snippet{"loop": {Prefix: snippet.Prefix{"loop"}, Scope: "go", Body: snippet.Body{"for {}"}}}
+	if got := readSnippets(t, filepath.Join(dir, "repo", "project", ".vscode", "go.code-snippets")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Without one, it is created at the repository root.
+	chdir(t, filepath.Join(dir, "repo", "other", "src"))
+	mustRun(t, "-local", ".")
+	if got := keys(t, filepath.Join(dir, "repo", ".vscode", "go.code-snippets")); !reflect.DeepEqual(got, []string{"retry"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repo", "other", ".vscode")); !os.IsNotExist(err) {
+		t.Errorf("-local wrote below the repository root: %v", err)
+	}
+
+	for _, flags := range [][]string{
+		{"-local", "-o", filepath.Join(dir, "out")},
+		{"-local", "-root", dir},
+		{"-local", "-format", "global"},
+	} {
+		if err := run(t, append(flags, ".")...); err == nil {
+			t.Errorf("%q: got no error", flags)
+		}
+	}
+	mustRun(t, "-local", "-format", "workspace", ".")
+}
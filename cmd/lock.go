@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// lockVersion is the version of the -lock file format.
+const lockVersion = 1
+
+// Lock records the sha256 hashes of the snippet files written, keyed by
+// their name in the output directory, for -check to verify that none of them
+// changed since.
+type Lock struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// writeLock writes into fileName the Lock of the snippet files of outputs,
+// as written into dir.
+func writeLock(fileName, dir string, outputs snippet.Outputs) error {
+	lock := Lock{Version: lockVersion, Files: make(map[string]string, len(outputs))}
+	for _, name := range outputs.Names() {
+		sum, err := hashFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		lock.Files[filepath.ToSlash(name)] = sum
+	}
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", fileName, err)
+	}
+	if err := os.WriteFile(fileName, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("%w %s: %w", snippet.ErrWriteFailed, fileName, err)
+	}
+	return nil
+}
+
+// verifyLock returns the snippet files of the Lock in fileName, within dir,
+// that are missing or whose content changed.
+func verifyLock(fileName, dir string) ([]string, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	var lock Lock
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", fileName, err)
+	}
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d", fileName, lock.Version)
+	}
+	names := make([]string, 0, len(lock.Files))
+	for name := range lock.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changed []string
+	for _, name := range names {
+		outputFile := filepath.Join(dir, filepath.FromSlash(name))
+		if sum, err := hashFile(outputFile); err != nil || sum != lock.Files[name] {
+			changed = append(changed, outputFile)
+		}
+	}
+	return changed, nil
+}
+
+// hashFile returns the hex-encoded sha256 hash of the content of fileName.
+func hashFile(fileName string) (string, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"out/go.json":        "{}\n",
+		"out/python/a.json":  "{\"a\": {}}\n",
+		"out/unrelated.json": "{}\n",
+	})
+	out := filepath.Join(dir, "out")
+	outputs := snippet.Outputs{"go.json": &snippet.Snippet{}, filepath.Join("python", "a.json"): &snippet.Snippet{}}
+	lockFile := filepath.Join(dir, "snippets.lock")
+	if err := writeLock(lockFile, out, outputs); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(lockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock Lock
+	if err := json.Unmarshal(b, &lock); err != nil {
+		t.Fatal(err)
+	}
+	want := Lock{Version: lockVersion, Files: map[string]string{
+		// The sha256 hashes of "{}\n" and "{\"a\": {}}\n".
+		"go.json":       "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356",
+		"python/a.json": "ddba97343dfe8ba3c0b08327856764bcc1bb1e733017c6375141536de4034d1f",
+	}}
+	if !reflect.DeepEqual(lock, want) {
+		t.Errorf("got %+v, want %+v", lock, want)
+	}
+	if changed, err := verifyLock(lockFile, out); err != nil || len(changed) != 0 {
+		t.Errorf("got %q, error %v for unchanged files", changed, err)
+	}
+
+	// Tampered and missing files are reported, in name order.
+	writeFiles(t, dir, map[string]string{"out/python/a.json": "{}\n", "out/unrelated.json": "[]\n"})
+	os.Remove(filepath.Join(out, "go.json"))
+	changed, err := verifyLock(lockFile, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(out, "go.json"), filepath.Join(out, "python", "a.json")}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("got %q, want %q", changed, want)
+	}
+
+	for name, content := range map[string]string{
+		"version": `{"version": 2, "files": {}}`,
+		"json":    `{"version": 1, "files": `,
+	} {
+		fileName := filepath.Join(dir, name+".lock")
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifyLock(fileName, out); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	if _, err := verifyLock(filepath.Join(dir, "missing.lock"), out); !errors.Is(err, snippet.ErrReadFailed) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrReadFailed)
+	}
+}
+
+func TestLockFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go": "for {}\n",
+		"src/loop.py": "while True: pass\n",
+	})
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	lockFile := filepath.Join(dir, "snippets.lock")
+	mustRun(t, "-o", out, "-lock", lockFile, src)
+	if changed, err := verifyLock(lockFile, out); err != nil || len(changed) != 0 {
+		t.Fatalf("got %q, error %v after writing", changed, err)
+	}
+	if stdout := captureStdout(t, func() { mustRun(t, "-o", out, "-lock", lockFile, "-check", src) }); stdout != "" {
+		t.Errorf("got %q listed for unchanged files", stdout)
+	}
+
+	// A tampered file is listed once, out of date and no longer matching
+	// the lock.
+	tampered := filepath.Join(out, "python.json")
+	writeFiles(t, out, map[string]string{"python.json": "{}\n"})
+	var err error
+	stdout := captureStdout(t, func() { err = run(t, "-o", out, "-lock", lockFile, "-check", src) })
+	if err == nil || stdout != tampered+"\n" {
+		t.Errorf("got %q, error %v, want %s listed", stdout, err, tampered)
+	}
+
+	// The lock still covers the files no longer generated.
+	mustRun(t, "-o", out, "-lock", lockFile, src)
+	os.Remove(filepath.Join(src, "loop.py"))
+	writeFiles(t, out, map[string]string{"python.json": "{}\n"})
+	stdout = captureStdout(t, func() { err = run(t, "-o", out, "-lock", lockFile, "-check", src) })
+	if err == nil || stdout != tampered+"\n" {
+		t.Errorf("got %q, error %v, want %s listed", stdout, err, tampered)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Level is the verbosity of the messages written to LogOutput.
+type Level int
+
+const (
+	// LevelQuiet only reports errors.
+	LevelQuiet Level = iota
+	// LevelNormal also reports warnings, such as skipped paths, and a
+	// summary of the generated files.
+	LevelNormal
+	// LevelVerbose also reports progress: each file added and written.
+	LevelVerbose
+)
+
+var LogLevel = LevelNormal
+var LogOutput io.Writer = os.Stderr
+
+func logf(level Level, format string, args ...interface{}) {
+	if level <= LogLevel {
+		fmt.Fprintf(LogOutput, format+"\n", args...)
+	}
+}
+
+func errorf(format string, args ...interface{}) {
+	logf(LevelQuiet, format, args...)
+}
+
+func warnf(format string, args ...interface{}) {
+	logf(LevelNormal, format, args...)
+}
+
+func infof(format string, args ...interface{}) {
+	logf(LevelNormal, format, args...)
+}
+
+func verbosef(format string, args ...interface{}) {
+	logf(LevelVerbose, format, args...)
+}
+
+// logger reports the progress of the snippet generation at the matching
+// levels.
+type logger struct{}
+
+func (logger) Warnf(format string, args ...interface{})    { warnf(format, args...) }
+func (logger) Infof(format string, args ...interface{})    { infof(format, args...) }
+func (logger) Verbosef(format string, args ...interface{}) { verbosef(format, args...) }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		flag     string
+		want     []string
+		dontWant []string
+	}{
+		{"normal", "", []string{"Wrote 1 snippets"}, []string{"adding"}},
+		{"verbose", "-v", []string{"adding ", "loop.go", "wrote 1 snippets to ", "Wrote 1 snippets"}, nil},
+		{"quiet", "-q", nil, []string{"adding", "Wrote"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+			arguments := []string{"-o", filepath.Join(dir, "out"), filepath.Join(dir, "loop.go")}
+			if tt.flag != "" {
+				arguments = append(arguments, tt.flag)
+			}
+			args, err := setup(t, arguments...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			LogOutput = &buf
+			if err := process(context.Background(), args); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(buf.String(), s) {
+					t.Errorf("got %q, want %q logged", buf.String(), s)
+				}
+			}
+			for _, s := range tt.dontWant {
+				if strings.Contains(buf.String(), s) {
+					t.Errorf("got %q, want no %q logged", buf.String(), s)
+				}
+			}
+		})
+	}
+}
+
+func TestVerboseAndQuiet(t *testing.T) {
+	if err := run(t, "-o", t.TempDir(), "-v", "-q", "."); err == nil {
+		t.Error("got no error for -v and -q")
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	saved, savedLevel := LogOutput, LogLevel
+	t.Cleanup(func() { LogOutput, LogLevel = saved, savedLevel })
+	LogOutput, LogLevel = &buf, LevelQuiet
+	warnf("warning")
+	verbosef("progress")
+	errorf("failure %d", 1)
+	if got, want := buf.String(), "failure 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":   "for {}\n",
+		"src/retry.go":  "for {}\n",
+		"src/switch.go": "switch {}\n",
+		"src/loop.py":   "while True: pass\n",
+		"src/loop.sh":   "while true; do :; done\n",
+	})
+	out := filepath.Join(dir, "out")
+	logged := func(flags ...string) string {
+		args, err := setup(t, append([]string{"-o", out, filepath.Join(dir, "src")}, flags...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		LogOutput = &buf
+		if err := process(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	cache := filepath.Join(dir, "cache.json")
+	want := "Wrote 5 snippets across 3 snippet files to " + out
+	if got := logged("-cache", cache); !strings.Contains(got, want) {
+		t.Errorf("got %q, want %q logged", got, want)
+	}
+	// With -cache, up to date files are not rewritten, so nothing is
+	// reported.
+	if got := logged("-cache", cache); strings.Contains(got, "Wrote") {
+		t.Errorf("got %q logged for up to date files", got)
+	}
+	if got := logged("-append-only"); strings.Contains(got, "Wrote") {
+		t.Errorf("got %q logged with nothing to append", got)
+	}
+	if got := logged("-q", "-force"); strings.Contains(got, "Wrote") {
+		t.Errorf("got %q logged with -q", got)
+	}
+}
@@ -1,138 +1,1146 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"vscode_snippet_generator/pkg/snippet"
 )
 
 var SpacesIndent string
+var UseTabs bool
 var OutputDir string
+var Edition string
+var NoExtError bool
+var DefaultLang string
+var Dotfiles string
+var PrefixTemplate string
+var PrefixNamespace string
+var PrefixWithExt bool
+var DescFrom string
+var DescPath bool
+var RelativeTo string
+var OnCollision string
+var DryRun bool
+var Merge bool
+var AppendOnly bool
+var Prune bool
+var Force bool
+var Strict bool
+var ValidateSchema bool
+var DirMode Mode
+var FileMode Mode
+var StdinName string
+var StdinExt string
+var Scope string
+var ScopeMap = Pairs{}
+var LangMap = Pairs{}
+var OutNames = Pairs{}
+var Escape bool
+var TabstopMarker string
+var Watch bool
+var Exclude List
+var Exts List
+var ExcludeExts List
+var Gitignore bool
+var SkipErrors bool
+var ShowVersion bool
+var Single string
+var GroupBy string
+var Split bool
+var Format string
+var Verbose bool
+var Quiet bool
+var Jobs int
+var Progress bool
+var NameFrom string
+var StripPrefix string
+var NameTemplate string
+var PathSep string
+var NameCase string
+var Aliases bool
+var Header string
+var PostHook string
+var BodyStyle string
+var SortBy string
+var OutputFormat string
+var TrimBlankLines bool
+var TrimTrailingWS bool
+var KeepTrailingNewline bool
+var PreserveCRLF bool
+var ExpandTabs int
+var Dedent bool
+var Transforms List
+var Stdout bool
+var ConfigPath string
+var CachePath string
+var DedupBodies string
+var Fence bool
+var DescMaxLen int
+var DescTemplate string
+var Wrap int
+var WrapMarker string
+var DescTimeFormat string
+var Clean bool
+var Directives List
+var SplitOn string
+var FileTemplate bool
+var Context string
+var FromFile string
+var RenamePath string
+var ReportPath string
+var IndexPath string
+var LockPath string
+var Check bool
+var FollowSymlinks bool
+var MaxDepth int
+var IncludeBinary bool
+var RequireMatch string
+var ExcludeMatch string
+var MaxSize = ByteSize(1 << 20)
+var ProjectRoot string
+var Local bool
+var WatchInterval time.Duration
+
+// VSCodeSnippetsFolder is the user snippets folder within the configuration
+// folder of a VS Code edition.
+const VSCodeSnippetsFolder = "User/snippets"
+
+// Editions maps the -edition values to the configuration folder name of
+// their VS Code builds.
+var Editions = map[string]string{
+	"stable":   "Code",
+	"insiders": "Code - Insiders",
+}
 
-const VSCodeSnippetsFolder = "Code/User/snippets"
+// WorkspaceFolder is the folder of a project holding its VS Code settings
+// and workspace snippets.
+const WorkspaceFolder = ".vscode"
+
+// GetDefaultOutputDirectory returns the user snippets folder of edition on
+// this system, or "" if it cannot be located.
+func GetDefaultOutputDirectory(edition string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+	return snippetsDir(runtime.GOOS, edition, home, os.Getenv)
+}
 
-func GetDefaultOutputDirectory() string {
+// snippetsDir returns the user snippets folder of edition on goos for the
+// user with the home directory home, reading the environment with getenv.
+// Portable installs keep it in their data folder, given by VSCODE_PORTABLE.
+// It returns "" if the folder cannot be located.
+func snippetsDir(goos, edition, home string, getenv func(string) string) string {
 	/*
 		See https://code.visualstudio.com/docs/getstarted/settings#_settings-file-locations
+		and https://code.visualstudio.com/docs/editor/portable
 	*/
-	home, err := os.UserConfigDir()
-	if err != nil {
+	if portable := getenv("VSCODE_PORTABLE"); portable != "" {
+		return filepath.Join(portable, "user-data", filepath.FromSlash(VSCodeSnippetsFolder))
+	}
+	var config string
+	switch goos {
+	case "windows":
+		config = getenv("APPDATA")
+		if config == "" && home != "" {
+			config = filepath.Join(home, "AppData", "Roaming")
+		}
+	case "darwin":
+		if home != "" {
+			config = filepath.Join(home, "Library", "Application Support")
+		}
+	default:
+		config = getenv("XDG_CONFIG_HOME")
+		if config == "" && home != "" {
+			config = filepath.Join(home, ".config")
+		}
+	}
+	if config == "" {
 		return ""
 	}
-	return filepath.Join(home, VSCodeSnippetsFolder)
+	return filepath.Join(config, Editions[edition], filepath.FromSlash(VSCodeSnippetsFolder))
 }
 
 func init() {
 	const spacesIndent = "    "
 
-	flag.StringVar(&SpacesIndent, "i", spacesIndent, "indentation")
-	flag.StringVar(&OutputDir, "o", GetDefaultOutputDirectory(), "path to VS Code snippets folder.")
+	flag.StringVar(&ConfigPath, "config", ConfigFile, "JSON file with defaults for -i, -o, -exclude and -lang-map.")
+	flag.StringVar(&SpacesIndent, "i", spacesIndent, "indentation: spaces, a number of spaces or \\t for tabs; or comma-separated LANG=INDENT pairs, \"default\" applying to the others.")
+	flag.BoolVar(&UseTabs, "tabs", false, "indent with tabs, overriding -i.")
+	flag.StringVar(&Edition, "edition", "stable", "VS Code edition whose user snippets folder is the default -o: stable or insiders.")
+	flag.StringVar(&OutputDir, "o", GetDefaultOutputDirectory("stable"), "path to VS Code snippets folder.")
+	flag.BoolVar(&NoExtError, "no-ext-error", false, "fail on files without extension instead of skipping them.")
+	flag.StringVar(&DefaultLang, "default-lang", "", "language for files without extension.")
+	flag.StringVar(&Dotfiles, "dotfiles", "lang", "dotfile naming: lang (.gitignore is the gitignore snippet of language gitignore, .eslintrc.json the eslintrc snippet of json) or noext (kept as is, .gitignore has no extension).")
+	flag.StringVar(&PrefixTemplate, "prefix", "{name}", "snippet prefix template; placeholders: {name}, {dir}, {ext}.")
+	flag.BoolVar(&PrefixWithExt, "prefix-with-ext", false, "append the file extension to prefixes, e.g. client.go, unless -prefix has {ext}.")
+	flag.StringVar(&PrefixNamespace, "prefix-namespace", "", "namespace prepended to every prefix, separator included, such as mylib.; snippet names are unchanged.")
+	flag.BoolVar(&Aliases, "aliases", false, "add the words in FILE.aliases sidecar files as additional prefixes.")
+	flag.StringVar(&Scope, "scope", "", "scope of the generated snippets, e.g. \"javascript,typescript\".")
+	flag.Var(ScopeMap, "scope-map", "comma-separated EXT=SCOPE pairs overriding -scope per extension; repeatable.")
+	flag.Var(LangMap, "lang-map", "comma-separated EXT=LANG pairs overriding the built-in extension to language id mapping; repeatable.")
+	flag.BoolVar(&FileTemplate, "file-template", false, "mark the snippets as file templates, offered by \"New File\"; frontmatter isFileTemplate overrides it per file.")
+	flag.Var(&Directives, "directives", "comma-separated @NAME: value comment directives to read at the top of files, NAME or NAME=FIELD, FIELD being "+strings.Join(snippet.MetadataKeys, ", ")+"; desc sets description; repeatable.")
+	flag.StringVar(&SplitOn, "split-on", "", "regular expression matching the delimiter lines splitting files into several snippets, named by its first capture group, e.g. \"^=== (.+) ===$\".")
+	flag.StringVar(&Context, "context", "", "when-clause context key stored as the x-context field of the snippets, e.g. editorLangId == go; ignored by VS Code, for other tools.")
+	flag.BoolVar(&Fence, "fence", false, "wrap bodies in Markdown code fences for their language.")
+	flag.StringVar(&DedupBodies, "dedup-bodies", "", "keep only the first snippet of a language among the ones with identical bodies: merge (adding their prefixes to it) or first; empty keeps them all.")
+	flag.StringVar(&BodyStyle, "body-style", "array", "body encoding: array (of lines) or auto (a string for single-line bodies).")
+	flag.BoolVar(&TrimBlankLines, "trim-blank-lines", false, "remove the leading and trailing blank lines of bodies.")
+	flag.IntVar(&Wrap, "wrap", 0, "split body lines longer than N characters into pieces of at most N characters ending with -wrap-marker, keeping escapes, tabstops and placeholders whole; VS Code inserts every piece on its own line, changing the snippet, so 0, the default, keeps lines whole.")
+	flag.StringVar(&WrapMarker, "wrap-marker", "\\", "continuation marker ending the pieces of the lines split by -wrap.")
+	flag.BoolVar(&TrimTrailingWS, "trim-trailing-ws", false, "remove the trailing spaces and tabs of body lines.")
+	flag.BoolVar(&KeepTrailingNewline, "keep-trailing-newline", false, "end bodies of files ending with newlines with an empty line.")
+	flag.BoolVar(&PreserveCRLF, "preserve-crlf", false, "keep the carriage returns of CRLF line endings in bodies.")
+	flag.IntVar(&ExpandTabs, "expand-tabs", 0, "expand tabs in bodies to tab stops every N columns; 0 keeps them.")
+	flag.Var(&Transforms, "transform", "comma-separated body transforms applied in order: "+strings.Join(snippet.TransformNames(), ", ")+"; repeatable.")
+	flag.BoolVar(&Dedent, "dedent", false, "remove the leading whitespace common to the non-blank lines of bodies.")
+	flag.BoolVar(&Escape, "escape", false, "escape $, } and \\ in bodies so VS Code inserts them literally.")
+	flag.StringVar(&TabstopMarker, "tabstop-marker", "", "regexp whose first capture group is a tabstop number, e.g. %%(\\d+)%%; matches become $N.")
+	flag.Var(&Exclude, "exclude", "comma-separated gitignore-style patterns to skip while walking; repeatable.")
+	flag.Var(&Exts, "ext", "comma-separated extensions or languages to process, e.g. go,ts,py; empty for all; repeatable.")
+	flag.Var(&ExcludeExts, "exclude-ext", "comma-separated extensions or languages to skip; repeatable.")
+	flag.BoolVar(&Gitignore, "gitignore", false, "skip the paths ignored by the nearest .gitignore.")
+	flag.BoolVar(&Verbose, "v", false, "verbose: report each file added and written.")
+	flag.BoolVar(&Quiet, "q", false, "quiet: only report errors.")
+	flag.BoolVar(&Progress, "progress", false, "show how many files were read, when stdout is a terminal.")
+	flag.IntVar(&Jobs, "jobs", runtime.GOMAXPROCS(0), "number of files read concurrently.")
+	flag.BoolVar(&ShowVersion, "version", false, "print version information and exit.")
+	flag.BoolVar(&FollowSymlinks, "follow-symlinks", false, "walk symbolic links to directories.")
+	flag.IntVar(&MaxDepth, "max-depth", -1, "how many directories deep to walk below each argument; 0 only takes its files, -1 has no limit.")
+	flag.Var(&MaxSize, "max-size", "skip files larger than this size in bytes; accepts k, m and g suffixes.")
+	flag.BoolVar(&IncludeBinary, "include-binary", false, "include files that do not look like text.")
+	flag.StringVar(&RequireMatch, "require-match", "", "regular expression skipping the files whose content it does not match, e.g. \"// snippet\".")
+	flag.StringVar(&ExcludeMatch, "exclude-match", "", "regular expression skipping the files whose content it matches.")
+	flag.BoolVar(&SkipErrors, "skip-errors", false, "report and skip unreadable paths instead of failing.")
+	flag.BoolVar(&Watch, "watch", false, "keep running and regenerate the snippets when the input files change.")
+	flag.DurationVar(&WatchInterval, "watch-interval", 500*time.Millisecond, "how often -watch polls the input files.")
+	flag.StringVar(&Format, "format", "global", "output format: global (LANG.json user snippets) or workspace (LANG.code-snippets in the project .vscode folder, always scoped).")
+	flag.StringVar(&ProjectRoot, "root", ".", "project root of -format workspace; its .vscode folder is the default output.")
+	flag.BoolVar(&Local, "local", false, "write workspace snippets into the .vscode folder of the nearest ancestor of the current directory having one, or else of the repository root; implies -format workspace.")
+	flag.Var(OutNames, "out-name", "comma-separated EXT=FILE pairs naming the snippet file of an extension or language; files shared by several are merged; repeatable.")
+	flag.BoolVar(&Split, "split", false, "write every snippet into its own LANG/NAME.json file.")
+	flag.StringVar(&GroupBy, "group-by", "ext", "grouping of the snippets into snippet files: ext (a file per language) or dir (a DIR.code-snippets file per top-level directory, relative to -strip-prefix or to the argument, scoped).")
+	flag.StringVar(&Single, "single", "", "write all the snippets, scoped to their language, into a single NAME.code-snippets file.")
+	flag.StringVar(&OutputFormat, "output-format", "json", "encoding of the snippet files: json, yaml or toml; snippet files are named accordingly.")
+	flag.StringVar(&SortBy, "sort-by", "name", "order of the snippets in snippet files: name or prefix.")
+	flag.StringVar(&Header, "header", "", "comment written at the top of every snippet file, e.g. \"Generated file, do not edit.\"; lines separated by newlines.")
+	flag.StringVar(&PostHook, "post-hook", "", "shell command run after the snippets are written, with the output directory in $"+HookDirEnv+".")
+	flag.BoolVar(&AppendOnly, "append-only", false, "only add the snippets missing from the existing snippet files, never changing the existing ones.")
+	flag.BoolVar(&Merge, "merge", false, "merge into existing snippet files, resolving conflicts with -on-collision.")
+	flag.BoolVar(&Strict, "strict", false, "fail on snippets VS Code would not load or offer: without prefix or body, or without scope in the workspace format.")
+	flag.BoolVar(&ValidateSchema, "validate-schema", false, "with -merge, check the existing snippet files and the merged content against the VS Code snippet schema.")
+	flag.BoolVar(&Force, "force", false, "overwrite read-only snippet files, making them writable.")
+	flag.Var(&DirMode, "dir-mode", "octal permissions of the created directories, before the umask; 0755 if unset.")
+	flag.Var(&FileMode, "file-mode", "octal permissions of the created snippet files, before the umask; 0666 if unset.")
+	flag.BoolVar(&Prune, "prune", false, "with -merge, remove the snippets previously generated with -prune whose files are gone; marks the snippets as generated.")
+	flag.BoolVar(&Stdout, "stdout", false, "print the generated files to stdout as a JSON object keyed by file name instead of writing them.")
+	flag.StringVar(&RenamePath, "rename", "", "file of OLD=NEW lines renaming snippets, applied to the derived names; collisions follow -on-collision.")
+	flag.StringVar(&FromFile, "from-file", "", "file listing the files to process, one per line, in addition to the arguments; blank lines and lines starting with # are skipped.")
+	flag.StringVar(&CachePath, "cache", "", "file caching the input files between runs, so that only the changed ones are read; snippet files are then only rewritten when they change.")
+	flag.StringVar(&ReportPath, "report", "", "file to write a JSON report of the generated snippets, skipped files and written snippet files into.")
+	flag.StringVar(&LockPath, "lock", "", "file recording the sha256 hashes of the written snippet files; -check also fails on the files that no longer match it.")
+	flag.StringVar(&IndexPath, "index", "", "file to write a JSON catalog of the generated snippets into: key, prefix, language, snippet file and description.")
+	flag.BoolVar(&Clean, "clean", false, "remove the snippet files written by previous -clean runs that are no longer generated; other files are kept.")
+	flag.BoolVar(&Check, "check", false, "list the snippet files that are out of date, failing if any, instead of writing them.")
+	flag.BoolVar(&DryRun, "dry-run", false, "print the files that would be written instead of writing them.")
+	flag.StringVar(&StdinName, "stdin-name", "stdin", "snippet name for content read from \"-\".")
+	flag.StringVar(&StdinExt, "stdin-ext", "", "extension for content read from \"-\".")
+	flag.StringVar(&NameFrom, "name-from", "base", "snippet name source: base (file name without extension) or path (path without extension, / separated).")
+	flag.StringVar(&StripPrefix, "strip-prefix", "", "directory stripped from the paths of -name-from path.")
+	flag.StringVar(&NameTemplate, "name-template", "", "snippet name template overriding -name-from; placeholders: {name}, {dir}, {ext} and {relpath}, the path without extension relative to -strip-prefix.")
+	flag.StringVar(&PathSep, "path-sep", "/", "separator replacing / in the {relpath} of -name-template, e.g. ., -, / or _.")
+	flag.StringVar(&NameCase, "name-case", "keep", "snippet name case: keep, lower, upper, kebab or snake.")
+	flag.StringVar(&OnCollision, "on-collision", "overwrite", "what to do when two files produce the same snippet name: error, overwrite or rename.")
+	flag.StringVar(&DescFrom, "desc-from", "none", "description source: sidecar (FILE.desc, falling back to firstline), firstline (leading comment), doc (leading comment block, removed from the body) or none.")
+	flag.StringVar(&DescTemplate, "desc-template", "", "template describing the snippets left without description; placeholders: {name}, {path}, {ext}, {mtime} and {size}, e.g. \"from {name}.{ext}, modified {mtime}\".")
+	flag.StringVar(&DescTimeFormat, "desc-time-format", time.RFC3339, "Go time layout of the {mtime} of -desc-template.")
+	flag.IntVar(&DescMaxLen, "desc-max-len", 0, "truncate descriptions longer than N characters with an ellipsis; 0 keeps them whole.")
+	flag.BoolVar(&DescPath, "desc-path", false, "describe the snippets left without description by their source path relative to -relative-to.")
+	flag.StringVar(&RelativeTo, "relative-to", ".", "root of the paths of -desc-path.")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stdout, "Usage:\n  %s [flags] (FILE|DIR)...\n\nFlags:\n", os.Args[0])
+		fmt.Fprintf(os.Stdout, "Usage:\n  %s [flags] (FILE|DIR|GLOB|ARCHIVE|-)...\n\nFlags:\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprint(os.Stdout, "\nEnvironment:\n  SNIPPETGEN_OUTPUT, SNIPPETGEN_INDENT and SNIPPETGEN_EXCLUDE default -o, -i and -exclude.\n")
+	}
+}
+
+// commandLine holds the flags given on the command line, recorded before
+// the environment and the configuration file set others.
+var commandLine = map[string]bool{}
+
+// recordCommandLine records the flags set so far as given on the command
+// line.
+func recordCommandLine() {
+	flag.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+}
+
+// isSet reports whether the flag name was set, on the command line, by the
+// environment or by the configuration file.
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// validateFlags checks the flag values and resolves the ones depending on
+// other flags.
+func validateFlags() error {
+	if !UseTabs {
+		var err error
+		if indents, err = parseIndent(SpacesIndent); err != nil {
+			return fmt.Errorf("-i: %w", err)
+		}
+	}
+	if err := snippet.ValidateTemplate(PrefixTemplate, "name", "dir", "ext"); err != nil {
+		return fmt.Errorf("-prefix: %w", err)
+	}
+	if err := snippet.ValidateTemplate(DescTemplate, "name", "path", "ext", "mtime", "size"); err != nil {
+		return fmt.Errorf("-desc-template: %w", err)
+	}
+	switch DescFrom {
+	case "sidecar", "firstline", "doc", "none":
+	default:
+		return fmt.Errorf("-desc-from: unknown source %q", DescFrom)
+	}
+	switch {
+	case Verbose && Quiet:
+		return errors.New("-v and -q are mutually exclusive")
+	case Verbose:
+		LogLevel = LevelVerbose
+	case Quiet:
+		LogLevel = LevelQuiet
+	}
+	if DescMaxLen < 0 {
+		return fmt.Errorf("-desc-max-len: %d is negative", DescMaxLen)
+	}
+	if Wrap < 0 {
+		return fmt.Errorf("-wrap: %d is negative", Wrap)
+	}
+	if ExpandTabs < 0 {
+		return fmt.Errorf("-expand-tabs: %d is negative", ExpandTabs)
+	}
+	if MaxDepth < -1 {
+		return fmt.Errorf("-max-depth: %d is below -1", MaxDepth)
+	}
+	if Jobs < 1 {
+		return fmt.Errorf("-jobs: %d is not positive", Jobs)
+	}
+	if _, ok := Editions[Edition]; !ok {
+		return fmt.Errorf("-edition: unknown edition %q", Edition)
+	}
+	if Local {
+		// The -o of the environment and the configuration file does not
+		// apply to -local.
+		switch {
+		case commandLine["o"]:
+			return errors.New("-local and -o are mutually exclusive")
+		case commandLine["root"]:
+			return errors.New("-local and -root are mutually exclusive")
+		case commandLine["format"] && Format != "workspace":
+			return fmt.Errorf("-local and -format %s are mutually exclusive", Format)
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("-local: %w", err)
+		}
+		root := localRoot(wd)
+		if rel, err := filepath.Rel(wd, root); err == nil {
+			root = rel
+		}
+		Format, ProjectRoot = "workspace", root
+	}
+	switch Format {
+	case "global":
+		if !isSet("o") {
+			OutputDir = GetDefaultOutputDirectory(Edition)
+		}
+	case "workspace":
+		if Local || !isSet("o") {
+			OutputDir = filepath.Join(ProjectRoot, WorkspaceFolder)
+		}
+	default:
+		return fmt.Errorf("-format: unknown format %q", Format)
+	}
+	if !Stdout {
+		if OutputDir == "" {
+			return errors.New("cannot locate the VS Code snippets folder; use -o")
+		}
+		verbosef("using output directory %s", OutputDir)
+	}
+	if Split && Single != "" {
+		return errors.New("-split and -single are mutually exclusive")
+	}
+	switch GroupBy {
+	case "ext":
+	case "dir":
+		switch {
+		case Split:
+			return errors.New("-group-by dir and -split are mutually exclusive")
+		case Single != "":
+			return errors.New("-group-by dir and -single are mutually exclusive")
+		case len(OutNames) > 0:
+			return errors.New("-group-by dir and -out-name are mutually exclusive")
+		}
+	default:
+		return fmt.Errorf("-group-by: unknown grouping %q", GroupBy)
+	}
+	if Prune && !Merge {
+		return errors.New("-prune requires -merge")
+	}
+	if ValidateSchema && !Merge {
+		return errors.New("-validate-schema requires -merge")
+	}
+	if Watch {
+		switch {
+		case Stdout:
+			return errors.New("-watch and -stdout are mutually exclusive")
+		case Check:
+			return errors.New("-watch and -check are mutually exclusive")
+		case DryRun:
+			return errors.New("-watch and -dry-run are mutually exclusive")
+		}
+	}
+	if AppendOnly {
+		switch {
+		case Merge:
+			return errors.New("-append-only and -merge are mutually exclusive")
+		case Clean:
+			return errors.New("-append-only and -clean are mutually exclusive")
+		}
+	}
+	switch DedupBodies {
+	case "", "merge", "first":
+	default:
+		return fmt.Errorf("-dedup-bodies: unknown policy %q", DedupBodies)
+	}
+	switch BodyStyle {
+	case "array", "auto":
+	default:
+		return fmt.Errorf("-body-style: unknown style %q", BodyStyle)
+	}
+	switch OutputFormat {
+	case "json":
+	case "yaml", "toml":
+		if Merge || AppendOnly {
+			return fmt.Errorf("-merge and -append-only require -output-format json")
+		}
+	default:
+		return fmt.Errorf("-output-format: unknown format %q", OutputFormat)
+	}
+	switch SortBy {
+	case "name", "prefix":
+	default:
+		return fmt.Errorf("-sort-by: unknown order %q", SortBy)
+	}
+	switch Dotfiles {
+	case "lang", "noext":
+	default:
+		return fmt.Errorf("-dotfiles: unknown naming %q", Dotfiles)
+	}
+	switch NameFrom {
+	case "base", "path":
+	default:
+		return fmt.Errorf("-name-from: unknown source %q", NameFrom)
 	}
+	if err := snippet.ValidateTemplate(NameTemplate, "name", "dir", "ext", "relpath"); err != nil {
+		return fmt.Errorf("-name-template: %w", err)
+	}
+	if PathSep == "" {
+		return errors.New("-path-sep: empty separator")
+	}
+	switch NameCase {
+	case "keep", "lower", "upper", "kebab", "snake":
+	default:
+		return fmt.Errorf("-name-case: unknown case %q", NameCase)
+	}
+	switch OnCollision {
+	case "error", "overwrite", "rename":
+	default:
+		return fmt.Errorf("-on-collision: unknown policy %q", OnCollision)
+	}
+	transforms = nil
+	for _, name := range Transforms {
+		t, ok := snippet.LookupTransform(name)
+		if !ok {
+			return fmt.Errorf("-transform: unknown transform %q", name)
+		}
+		transforms = append(transforms, t)
+	}
+	if TabstopMarker != "" {
+		re, err := regexp.Compile(TabstopMarker)
+		if err != nil {
+			return fmt.Errorf("-tabstop-marker: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return fmt.Errorf("-tabstop-marker: %q has no capture group", TabstopMarker)
+		}
+		tabstopRe = re
+	}
+	for _, d := range Directives {
+		name, field, ok := strings.Cut(d, "=")
+		if !ok {
+			field = name
+			if name == "desc" {
+				field = "description"
+			}
+		}
+		if !isMetadataKey(field) {
+			return fmt.Errorf("-directives: unknown field %q", field)
+		}
+		if directives == nil {
+			directives = map[string]string{}
+		}
+		directives[name] = field
+	}
+	if RenamePath != "" {
+		m, err := readRenames(RenamePath)
+		if err != nil {
+			return fmt.Errorf("-rename: %w", err)
+		}
+		renames = m
+	}
+	for _, m := range []struct {
+		flag, expr string
+		re         **regexp.Regexp
+	}{
+		{"-require-match", RequireMatch, &requireMatchRe},
+		{"-exclude-match", ExcludeMatch, &excludeMatchRe},
+	} {
+		if m.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(m.expr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.flag, err)
+		}
+		*m.re = re
+	}
+	if SplitOn != "" {
+		re, err := regexp.Compile(SplitOn)
+		if err != nil {
+			return fmt.Errorf("-split-on: %w", err)
+		}
+		splitOnRe = re
+	}
+	return nil
+}
+
+// validateArgs checks the positional arguments args against the flags.
+func validateArgs(args []string) error {
+	for _, pathName := range args {
+		if pathName != "-" {
+			continue
+		}
+		if Watch {
+			return errors.New("-watch cannot read from stdin")
+		}
+		// Content without extension nor default language would be
+		// skipped without a word.
+		if filepath.Ext(stdinPath()) == "" && DefaultLang == "" {
+			return errors.New("reading from stdin requires -stdin-ext or -default-lang")
+		}
+	}
+	return nil
 }
 
-type Body []byte
+// Pairs is a flag.Value collecting comma-separated KEY=VALUE pairs.
+type Pairs map[string]string
 
-func (b *Body) MarshalJSON() ([]byte, error) {
-	return json.Marshal(strings.Split(strings.TrimRight(string(*b), "\n"), "\n"))
+func (p Pairs) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + p[k]
+	}
+	return strings.Join(keys, ",")
 }
 
-type File struct {
-	Prefix      string `json:"prefix"`
-	Description string `json:"description"`
-	Body        Body   `json:"body"`
+func (p Pairs) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid pair %q, expected KEY=VALUE", pair)
+		}
+		p[k] = v
+	}
+	return nil
 }
 
-type Snippet map[string]*File
+// List is a flag.Value collecting comma-separated values.
+type List []string
 
-func (s *Snippet) AddFile(pathName string) error {
-	_, fileName := filepath.Split(pathName)
-	baseName := fileName[:len(fileName)-len(filepath.Ext(fileName))]
+func (l *List) String() string {
+	return strings.Join(*l, ",")
+}
 
-	b, err := os.ReadFile(pathName)
-	if err != nil {
-		return fmt.Errorf("reading %s: %w", pathName, err)
+func (l *List) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// ByteSize is a flag.Value for a size in bytes, with an optional k, m or g
+// (binary) multiplier suffix.
+type ByteSize int64
+
+var sizeSuffixes = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+}
+
+func (b ByteSize) String() string {
+	for _, s := range sizeSuffixes {
+		if b != 0 && b%s.size == 0 {
+			return strconv.FormatInt(int64(b/s.size), 10) + s.suffix
+		}
 	}
+	return strconv.FormatInt(int64(b), 10)
+}
 
-	(*s)[baseName] = &File{
-		Prefix:      baseName,
-		Description: "",
-		Body:        Body(b),
+func (b *ByteSize) Set(value string) error {
+	number, multiplier := strings.ToLower(value), ByteSize(1)
+	for _, s := range sizeSuffixes {
+		if strings.HasSuffix(number, s.suffix) {
+			number, multiplier = strings.TrimSuffix(number, s.suffix), s.size
+			break
+		}
 	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*b = ByteSize(n) * multiplier
 	return nil
 }
 
-type Snippets map[string]*Snippet
+// Mode is a flag.Value for octal file permissions, such as 0750.
+type Mode fs.FileMode
 
-func (s *Snippets) AddSnippet(pathName string) error {
-	ext := filepath.Ext(pathName)[1:]
-	_, ok := (*s)[ext]
-	if !ok {
-		(*s)[ext] = &Snippet{}
+func (m Mode) String() string {
+	if m == 0 {
+		return ""
 	}
-	return ((*s)[ext]).AddFile(pathName)
+	return fmt.Sprintf("%#o", uint32(m))
 }
 
-func (s *Snippets) Write(pathName string) error {
-	for k, v := range *s {
-		fileName := filepath.Join(pathName, k+".json")
-		f, err := os.Create(fileName)
+func (m *Mode) Set(value string) error {
+	n, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || n == 0 || n&^uint64(fs.ModePerm) != 0 {
+		return fmt.Errorf("invalid octal permissions %q", value)
+	}
+	*m = Mode(n)
+	return nil
+}
+
+// indents maps languages to their JSON indentation, parsed from -i. The
+// empty language holds the default.
+var indents = map[string]string{"": snippet.DefaultIndent}
+
+// tabstopRe is the compiled -tabstop-marker.
+var tabstopRe *regexp.Regexp
+
+// splitOnRe is the compiled -split-on.
+var splitOnRe *regexp.Regexp
+
+// requireMatchRe and excludeMatchRe are the compiled -require-match and
+// -exclude-match.
+var requireMatchRe, excludeMatchRe *regexp.Regexp
+
+// renames is the -rename mapping.
+var renames map[string]string
+
+// directives are the -directives, mapped to their fields.
+var directives map[string]string
+
+func isMetadataKey(field string) bool {
+	for _, key := range snippet.MetadataKeys {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// transforms are the -transform transforms.
+var transforms []snippet.BodyTransform
+
+// parseIndent parses an indentation, or comma-separated LANG=INDENT pairs
+// where "default" applies to the languages not listed. Languages may be
+// given by extension. An indentation is either spaces, a number of spaces
+// or a tab, written \t.
+func parseIndent(value string) (map[string]string, error) {
+	if !strings.Contains(value, "=") {
+		indent, err := parseIndentValue(value)
 		if err != nil {
-			return fmt.Errorf("creating %s: %w", fileName, err)
+			return nil, err
 		}
-		defer f.Close()
+		return map[string]string{"": indent}, nil
+	}
 
-		enc := json.NewEncoder(f)
-		enc.SetIndent("", SpacesIndent)
-		if err := enc.Encode(v); err != nil {
-			return fmt.Errorf("encoding %s: %w", fileName, err)
+	parsed := map[string]string{"": indents[""]}
+	pairs := Pairs{}
+	if err := pairs.Set(value); err != nil {
+		return nil, err
+	}
+	langs := snippet.Options{LangMap: LangMap}
+	for lang, v := range pairs {
+		indent, err := parseIndentValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lang, err)
+		}
+		if lang == "default" {
+			parsed[""] = indent
+			continue
 		}
+		parsed[langs.LangOf(lang)] = indent
+	}
+	return parsed, nil
+}
+
+func parseIndentValue(value string) (string, error) {
+	if value == "\t" || value == `\t` {
+		return "\t", nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return strings.Repeat(" ", n), nil
+	}
+	if strings.Trim(value, " ") != "" {
+		return "", fmt.Errorf("%q is neither spaces, a number of spaces nor a tab", value)
 	}
+	return value, nil
+}
+
+// options returns the snippet generation options set by the flags.
+func options() snippet.Options {
+	indent := indents
+	if UseTabs {
+		indent = map[string]string{"": "\t"}
+	}
+	return snippet.Options{
+		PrefixTemplate:      PrefixTemplate,
+		PrefixNamespace:     PrefixNamespace,
+		PrefixWithExt:       PrefixWithExt,
+		Aliases:             Aliases,
+		DescFrom:            DescFrom,
+		DescMaxLen:          DescMaxLen,
+		DescPath:            DescPath,
+		RelativeTo:          RelativeTo,
+		DescTemplate:        DescTemplate,
+		DescTimeFormat:      DescTimeFormat,
+		Scope:               Scope,
+		ScopeMap:            ScopeMap,
+		NameFrom:            NameFrom,
+		StripPrefix:         StripPrefix,
+		NameTemplate:        NameTemplate,
+		PathSep:             PathSep,
+		NameCase:            NameCase,
+		Rename:              renames,
+		OnCollision:         OnCollision,
+		LangMap:             LangMap,
+		Dotfiles:            Dotfiles,
+		DefaultLang:         DefaultLang,
+		NoExtError:          NoExtError,
+		Exts:                Exts,
+		ExcludeExts:         ExcludeExts,
+		MaxSize:             int64(MaxSize),
+		IncludeBinary:       IncludeBinary,
+		RequireMatch:        requireMatchRe,
+		ExcludeMatch:        excludeMatchRe,
+		Transforms:          transforms,
+		Escape:              Escape,
+		TabstopMarker:       tabstopRe,
+		TrimBlankLines:      TrimBlankLines,
+		TrimTrailingSpace:   TrimTrailingWS,
+		KeepTrailingNewline: KeepTrailingNewline,
+		PreserveCRLF:        PreserveCRLF,
+		ExpandTabs:          ExpandTabs,
+		Dedent:              Dedent,
+		Wrap:                Wrap,
+		WrapMarker:          WrapMarker,
+		FileTemplate:        FileTemplate,
+		SplitOn:             splitOnRe,
+		Directives:          directives,
+		Context:             Context,
+		Fence:               Fence,
+		DedupBodies:         DedupBodies,
+		BodyStyle:           BodyStyle,
+		Indent:              indent,
+		SortBy:              SortBy,
+		OutputFormat:        OutputFormat,
+		Header:              Header,
+		Format:              Format,
+		OutNames:            OutNames,
+		Split:               Split,
+		Single:              Single,
+		GroupBy:             GroupBy,
+		Merge:               Merge,
+		AppendOnly:          AppendOnly,
+		Prune:               Prune,
+		DirMode:             fs.FileMode(DirMode),
+		FileMode:            fs.FileMode(FileMode),
+		Strict:              Strict,
+		ValidateSchema:      ValidateSchema,
+		Force:               Force,
+		Clean:               Clean,
+		SkipUnchanged:       CachePath != "",
+		Logger:              logger{},
+		OnSkip:              skip,
+	}
+}
+
+// stdinPath returns the logical path name of the content read from stdin.
+func stdinPath() string {
+	if StdinExt == "" {
+		return StdinName
+	}
+	return StdinName + "." + StdinExt
+}
+
+// walkError returns err, the error walking pathName, unless SkipErrors is
+// set, in which case it is reported and the walk goes on.
+func walkError(pathName string, err error) error {
+	if !SkipErrors {
+		return err
+	}
+	warnf("skipping %s: %v", pathName, err)
 	return nil
 }
 
-func process(ctx context.Context) error {
-	snippets := Snippets{}
-	for _, pathName := range os.Args[1:] {
-		if err := filepath.Walk(pathName, func(path string, info fs.FileInfo, err error) error {
-			if info.IsDir() {
-				return nil
+// generate returns the snippets for the files in args, which may be glob
+// patterns.
+func generate(ctx context.Context, args []string) (*snippet.Snippets, error) {
+	paths, err := expandArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	snippets := snippet.New(options())
+	skipped = nil
+
+	var sources []source
+	var archives []string
+	for _, pathName := range paths {
+		if snippet.IsArchive(pathName) {
+			archives = append(archives, pathName)
+			continue
+		}
+		if pathName == "-" {
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("%w stdin: %w", snippet.ErrReadFailed, err)
+			}
+			sources = append(sources, source{pathName: stdinPath(), content: b, read: true})
+			continue
+		}
+		ignores, err := ignoresFor(pathName)
+		if err != nil {
+			return nil, err
+		}
+		root := pathName
+		if info, err := os.Stat(pathName); err == nil && !info.IsDir() {
+			root = filepath.Dir(pathName)
+			igs, err := fileSnippetignores(pathName)
+			if err != nil {
+				return nil, err
+			}
+			ignores = append(ignores, igs...)
+		}
+		if err := walk(pathName, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return walkError(path, err)
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Files of the directories at MaxDepth are at MaxDepth+1.
+			if MaxDepth >= 0 && info.IsDir() && depth(pathName, path) > MaxDepth {
+				return filepath.SkipDir
+			}
+			if skip, err := ignores.visit(path, info); skip || err != nil || info.IsDir() {
+				return err
 			}
 
-			return snippets.AddSnippet(path)
+			if lang, err := snippets.Options.Language(path); lang == "" {
+				return err
+			}
+			sources = append(sources, source{pathName: path, root: root})
+			return nil
 		}); err != nil {
-			return fmt.Errorf("walking %s: %w", pathName, err)
+			return nil, fmt.Errorf("walking %s: %w", pathName, err)
+		}
+	}
+	if FromFile != "" {
+		// The listed files are taken as they are, without walking nor
+		// ignore patterns.
+		listed, err := readManifest(FromFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range listed {
+			if lang, err := snippets.Options.Language(path); lang == "" {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			sources = append(sources, source{pathName: path})
+		}
+	}
+
+	var cache *Cache
+	if CachePath != "" {
+		cache = loadCache(CachePath)
+		cache.fill(sources)
+	}
+	// Files are read concurrently but added in walk order, so that
+	// collisions are always resolved the same way.
+	if err := readSources(ctx, sources); err != nil {
+		return nil, err
+	}
+	for _, src := range sources {
+		if err := snippets.AddReaderIn(src.root, src.pathName, bytes.NewReader(src.content)); err != nil {
+			return nil, err
+		}
+	}
+	// -check and -dry-run leave the tree as it is, cache included.
+	if cache != nil && !Check && !DryRun {
+		cache.update(sources, skipped)
+		if err := cache.save(CachePath); err != nil {
+			return nil, err
+		}
+	}
+	// Archives are added after the other files, in argument order.
+	for _, pathName := range archives {
+		if err := snippets.AddArchive(pathName); err != nil {
+			return nil, err
 		}
 	}
+	return snippets, nil
+}
+
+func process(ctx context.Context, args []string) error {
+	snippets, err := generate(ctx, args)
+	if err != nil {
+		return err
+	}
+	if DryRun {
+		return snippets.DryRun(os.Stdout, OutputDir)
+	}
+	if Check {
+		stale, err := snippets.Check(OutputDir)
+		if err != nil {
+			return err
+		}
+		if LockPath != "" {
+			changed, err := verifyLock(LockPath, OutputDir)
+			if err != nil {
+				return err
+			}
+			stale = appendMissing(stale, changed)
+		}
+		for _, fileName := range stale {
+			fmt.Fprintln(os.Stdout, fileName)
+		}
+		if len(stale) > 0 {
+			return fmt.Errorf("%d snippet files are out of date", len(stale))
+		}
+		return nil
+	}
+	if Stdout {
+		outputs, err := snippets.Outputs()
+		if err != nil {
+			return err
+		}
+		if err := snippets.Options.EncodeOutputs(os.Stdout, outputs); err != nil {
+			return err
+		}
+		return writeRecords(snippets, outputs, "")
+	}
 
 	// create output folder if does not exist.
-	if _, err := os.Stat(OutputDir); errors.Is(err, os.ErrNotExist) {
-		if err := os.MkdirAll(OutputDir, 0755); err != nil {
-			return fmt.Errorf("creating %s: %w", OutputDir, err)
+	if info, err := os.Stat(OutputDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(OutputDir, snippets.Options.DirPerm()); err != nil {
+			return fmt.Errorf("%w %s: creating: %w", snippet.ErrWriteFailed, OutputDir, err)
+		}
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("output path %s exists and is not a directory", OutputDir)
+	}
+
+	if err := snippets.Write(ctx, OutputDir); err != nil {
+		if errors.Is(err, snippet.ErrReadOnly) {
+			return fmt.Errorf("%w; use -force to overwrite it", err)
 		}
+		return err
+	}
+	outputs, err := snippets.Outputs()
+	if err != nil {
+		return err
+	}
+	if err := writeRecords(snippets, outputs, OutputDir); err != nil {
+		return err
+	}
+	if err := runHook(ctx, OutputDir); err != nil {
+		return err
 	}
 
-	return snippets.Write(OutputDir)
+	if Watch {
+		return watch(ctx, args, snippets)
+	}
+	return nil
+}
+
+// writeRecords writes the -index, -report and -lock files of snippets
+// and their outputs, written into dir, or to stdout if dir is empty.
+func writeRecords(snippets *snippet.Snippets, outputs snippet.Outputs, dir string) error {
+	if IndexPath != "" {
+		if err := writeIndex(IndexPath, snippets); err != nil {
+			return err
+		}
+	}
+	if ReportPath != "" {
+		if err := writeReport(ReportPath, snippets, outputs, dir); err != nil {
+			return err
+		}
+	}
+	if LockPath != "" && dir != "" {
+		if err := writeLock(LockPath, dir, outputs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendMissing appends to names the ones of more it does not have.
+func appendMissing(names, more []string) []string {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, name := range more {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseArgs parses arguments with fs, allowing flags and positional
+// arguments to be interleaved. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, arguments []string) ([]string, error) {
+	var args []string
+	for {
+		if err := fs.Parse(arguments); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if terminated(fs, arguments[:len(arguments)-len(rest)]) {
+			return append(args, rest...), nil
+		}
+		if len(rest) == 0 {
+			return args, nil
+		}
+		args = append(args, rest[0])
+		arguments = rest[1:]
+	}
+}
+
+// terminated reports whether consumed, the arguments fs.Parse consumed,
+// end with a "--" terminator rather than with "--" as the value of a flag.
+func terminated(fs *flag.FlagSet, consumed []string) bool {
+	for i := 0; i < len(consumed); i++ {
+		arg := consumed[i]
+		if arg == "--" {
+			return true
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		// Flags other than booleans take the next argument as their value.
+		if f := fs.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++
+			}
+		}
+	}
+	return false
 }
 
 func main() {
-	flag.Parse()
-	if len(os.Args) <= 1 {
+	args, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	recordCommandLine()
+	if ShowVersion {
+		printVersion(os.Stdout)
+		return
+	}
+	// Flags take precedence over the environment, which takes precedence
+	// over the configuration file and then the built-in defaults.
+	if err := applyEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if err := applyConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if err := validateFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if err := validateArgs(args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if len(args) == 0 && FromFile == "" {
 		flag.Usage()
+		os.Exit(2)
 	}
 
-	ctx := context.Background()
-	if err := process(ctx); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := process(ctx, args); err != nil {
+		errorf("%v", err)
+		stop()
+		// A failing -post-hook exits with its status.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,1161 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// defaultFlags is the flag set the flags are registered into by init, before
+// the tests replace it.
+var defaultFlags = flag.CommandLine
+
+// resetFlags resets the flags, and the state derived from them, to their
+// defaults, with none of them set.
+func resetFlags(t testing.TB) {
+	t.Helper()
+	fs := flag.NewFlagSet(defaultFlags.Name(), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	defaultFlags.VisitAll(func(f *flag.Flag) {
+		// The flags of the testing package are left alone.
+		if strings.HasPrefix(f.Name, "test.") {
+			return
+		}
+		switch v := f.Value.(type) {
+		case Pairs:
+			for k := range v {
+				delete(v, k)
+			}
+		case *List:
+			*v = nil
+		case *Mode:
+			*v = 0
+		default:
+			if err := f.Value.Set(f.DefValue); err != nil {
+				t.Fatalf("resetting -%s: %v", f.Name, err)
+			}
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	flag.CommandLine = fs
+	t.Cleanup(func() { flag.CommandLine = defaultFlags })
+
+	commandLine = map[string]bool{}
+	indents = map[string]string{"": snippet.DefaultIndent}
+	tabstopRe, splitOnRe, requireMatchRe, excludeMatchRe = nil, nil, nil, nil
+	renames, directives, transforms, skipped = nil, nil, nil, nil
+	LogLevel, LogOutput = LevelNormal, io.Discard
+}
+
+// setup parses arguments as main does, with the flags reset first, and
+// returns the positional arguments.
+func setup(t testing.TB, arguments ...string) ([]string, error) {
+	t.Helper()
+	resetFlags(t)
+	args, err := parseArgs(flag.CommandLine, arguments)
+	if err != nil {
+		return nil, err
+	}
+	recordCommandLine()
+	if err := applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := applyConfig(); err != nil {
+		return nil, err
+	}
+	if err := validateFlags(); err != nil {
+		return nil, err
+	}
+	if err := validateArgs(args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// run runs the command with arguments.
+func run(t testing.TB, arguments ...string) error {
+	t.Helper()
+	args, err := setup(t, arguments...)
+	if err != nil {
+		return err
+	}
+	return process(context.Background(), args)
+}
+
+// mustRun runs the command with arguments, failing the test on errors.
+func mustRun(t testing.TB, arguments ...string) {
+	t.Helper()
+	if err := run(t, arguments...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFiles creates the files, keyed by path relative to dir, with their
+// content.
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readSnippets decodes the JSON snippet file fileName.
+func readSnippets(t *testing.T, fileName string) snippet.Snippet {
+	t.Helper()
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := snippet.Snippet{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("decoding %s: %v", fileName, err)
+	}
+	return s
+}
+
+// keys returns the sorted keys of the snippet file fileName.
+func keys(t *testing.T, fileName string) []string {
+	t.Helper()
+	return readSnippets(t, fileName).Keys()
+}
+
+// chdir changes the current directory to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		arguments []string
+		want      []string
+		wantOut   string
+	}{
+		{"flags first", []string{"-o", "out", "a", "b"}, []string{"a", "b"}, "out"},
+		{"flags last", []string{"a", "b", "-o", "out"}, []string{"a", "b"}, "out"},
+		{"interleaved", []string{"a", "-o", "out", "b", "-q"}, []string{"a", "b"}, "out"},
+		{"double dash", []string{"-o", "out", "--", "-q", "a"}, []string{"-q", "a"}, "out"},
+		{"no paths", []string{"-o", "out"}, nil, "out"},
+		{"double dash value", []string{"-wrap-marker", "--", "a", "-o", "out"}, []string{"a"}, "out"},
+		{"double dash after value", []string{"-o", "out", "-wrap-marker", "--", "--", "-o", "x"}, []string{"-o", "x"}, "out"},
+		{"double dash after bool", []string{"-o", "out", "-q", "--", "-o", "x"}, []string{"-o", "x"}, "out"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t)
+			got, err := parseArgs(flag.CommandLine, tt.arguments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got args %q, want %q", got, tt.want)
+			}
+			if OutputDir != tt.wantOut {
+				t.Errorf("got -o %q, want %q", OutputDir, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestParseArgsUnknownFlag(t *testing.T) {
+	resetFlags(t)
+	if _, err := parseArgs(flag.CommandLine, []string{"a", "-no-such-flag"}); err == nil {
+		t.Error("got no error for an unknown flag")
+	}
+}
+
+func TestProcessInterleavedFlags(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/alpha.go": "package a\n",
+		"b/beta.py":  "print()\n",
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, filepath.Join(dir, "a"), "-o", out, "-q", filepath.Join(dir, "b"), "-i", "2")
+
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"alpha"}) {
+		t.Errorf("go.json: got %q", got)
+	}
+	if got := keys(t, filepath.Join(out, "python.json")); !reflect.DeepEqual(got, []string{"beta"}) {
+		t.Errorf("python.json: got %q", got)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d snippet files, want 2", len(entries))
+	}
+}
+
+func TestDefaultLangFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM scratch\n"})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-default-lang", "dockerfile", filepath.Join(dir, "Dockerfile"))
+	if got := keys(t, filepath.Join(out, "dockerfile.json")); !reflect.DeepEqual(got, []string{"Dockerfile"}) {
+		t.Errorf("dockerfile.json: got %q", got)
+	}
+}
+
+func TestPrefixFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"net/retry.go": "for {}\n"})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-prefix", "{dir}:{name}", dir)
+	s := readSnippets(t, filepath.Join(out, "go.json"))
+	if got, want := s["retry"].Prefix, (snippet.Prefix{"net:retry"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got prefix %q, want %q", got, want)
+	}
+
+	if err := run(t, "-o", out, "-prefix", "{nmae}", dir); err == nil || !strings.Contains(err.Error(), "-prefix") {
+		t.Errorf("got error %v for an unknown placeholder", err)
+	}
+}
+
+func TestTabsFlag(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{"default", nil, "\n    \""},
+		{"spaces", []string{"-i", "2"}, "\n  \""},
+		{"tab", []string{"-i", `\t`}, "\n\t\""},
+		{"tabs", []string{"-tabs"}, "\n\t\""},
+		{"tabs win", []string{"-tabs", "-i", "2"}, "\n\t\""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+			out := filepath.Join(dir, "out")
+			mustRun(t, append([]string{"-o", out, filepath.Join(dir, "loop.go")}, tt.flags...)...)
+			b, err := os.ReadFile(filepath.Join(out, "go.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(b), "{"+tt.want+"loop\"") {
+				t.Errorf("got %q, want entries indented with %q", b, tt.want[1:len(tt.want)-1])
+			}
+		})
+	}
+}
+
+func TestParseIndent(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"    ", map[string]string{"": "    "}, false},
+		{"2", map[string]string{"": "  "}, false},
+		{`\t`, map[string]string{"": "\t"}, false},
+		{"\t", map[string]string{"": "\t"}, false},
+		{"", map[string]string{"": ""}, false},
+		{"  x", nil, true},
+		{" \t", nil, true},
+		{"\t\t", nil, true},
+		{"-2", nil, true},
+		{"default=2", map[string]string{"": "  "}, false},
+		{"default=2,py=4", map[string]string{"": "  ", "python": "    "}, false},
+		{"js=2,go=\\t", map[string]string{"": "    ", "javascript": "  ", "go": "\t"}, false},
+		{"py=x", nil, true},
+		{"default=2,py", nil, true},
+	} {
+		resetFlags(t)
+		got, err := parseIndent(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidIndentFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+	out := filepath.Join(dir, "out")
+	if err := run(t, "-o", out, "-i", "  x", filepath.Join(dir, "loop.go")); err == nil {
+		t.Error("got no error for -i \"  x\"")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("invalid indentation created %s: %v", out, err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":  "for {}\n",
+		"src/retry.go": "for {}\n",
+		"src/loop.py":  "while True: pass\n",
+	})
+	out := filepath.Join(dir, "out")
+	stdout := captureStdout(t, func() {
+		mustRun(t, "-o", out, "-dry-run", filepath.Join(dir, "src"))
+	})
+	want := filepath.Join(out, "go.json") + ": 2 snippets\n" + filepath.Join(out, "python.json") + ": 1 snippets\n"
+	if stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s: %v", out, err)
+	}
+}
+
+// setStdin makes os.Stdin read content for the rest of the test.
+func setStdin(t *testing.T, content string) {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestStdin(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		flags    []string
+		wantFile string
+		wantKey  string
+	}{
+		{"named", []string{"-stdin-name", "gen", "-stdin-ext", "go"}, "go.json", "gen"},
+		{"default lang", []string{"-default-lang", "text"}, "text.json", "stdin"},
+		{"name with extension", []string{"-stdin-name", "gen.py"}, "python.json", "gen"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setStdin(t, "generated\nbody\n")
+			out := filepath.Join(t.TempDir(), "out")
+			mustRun(t, append([]string{"-o", out, "-"}, tt.flags...)...)
+			s := readSnippets(t, filepath.Join(out, tt.wantFile))
+			file, ok := s[tt.wantKey]
+			if !ok {
+				t.Fatalf("got keys %q, want %s", s.Keys(), tt.wantKey)
+			}
+			if want := (snippet.Body{"generated", "body"}); !reflect.DeepEqual(file.Body, want) {
+				t.Errorf("got body %q, want %q", file.Body, want)
+			}
+		})
+	}
+}
+
+func TestStdinWithoutLanguage(t *testing.T) {
+	setStdin(t, "hi\n")
+	out := filepath.Join(t.TempDir(), "out")
+	if err := run(t, "-o", out, "-"); err == nil {
+		t.Error("got no error reading stdin without -stdin-ext or -default-lang")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("got %s written: %v", out, err)
+	}
+}
+
+func TestLangMapFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"app.ts": "export {}\n", "page.tmpl": "{{.}}\n"})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-lang-map", "tmpl=go,ts=tsx", dir)
+	for file, key := range map[string]string{"go.json": "page", "tsx.json": "app"} {
+		if got := keys(t, filepath.Join(out, file)); !reflect.DeepEqual(got, []string{key}) {
+			t.Errorf("%s: got %q", file, got)
+		}
+	}
+	if err := run(t, "-o", out, "-lang-map", "tmpl", dir); err == nil {
+		t.Error("got no error for an invalid pair")
+	}
+}
+
+func TestProcessCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n", "src/loop.py": "pass\n"})
+	out := filepath.Join(dir, "out")
+	args, err := setup(t, "-o", out, filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := process(ctx, args); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("cancelled run created %s: %v", out, err)
+	}
+}
+
+func TestWorkspaceFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	mustRun(t, "-format", "workspace", "-root", dir, filepath.Join(dir, "src"))
+	s := readSnippets(t, filepath.Join(dir, ".vscode", "go.code-snippets"))
+	if got := s["loop"]; got == nil || got.Scope != "go" {
+		t.Errorf("got %+v, want the loop snippet scoped to go", got)
+	}
+
+	if err := run(t, "-format", "local", filepath.Join(dir, "src")); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
+
+func TestStdoutFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n", "src/main.py": "pass\n"})
+	out := filepath.Join(dir, "out")
+	stdout := captureStdout(t, func() {
+		mustRun(t, "-o", out, "-stdout", "-i", "2", filepath.Join(dir, "src"))
+	})
+	var got map[string]snippet.Snippet
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if len(got) != 2 || got["go.json"]["loop"] == nil || got["python.json"]["main"] == nil {
+		t.Errorf("got %q", stdout)
+	}
+	if !strings.HasPrefix(stdout, "{\n  \"go.json\"") {
+		t.Errorf("got %q, want it indented with -i", stdout)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("-stdout created %s: %v", out, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go": "for {}\n",
+		"src/loop.py": "while True: pass\n",
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, filepath.Join(dir, "src"))
+
+	stdout := captureStdout(t, func() {
+		mustRun(t, "-o", out, "-check", filepath.Join(dir, "src"))
+	})
+	if stdout != "" {
+		t.Errorf("got %q listed for up to date files", stdout)
+	}
+
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for i := 0; ; i++ {}\n"})
+	stale := filepath.Join(out, "go.json")
+	before, err := os.ReadFile(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runErr error
+	stdout = captureStdout(t, func() {
+		runErr = run(t, "-o", out, "-check", filepath.Join(dir, "src"))
+	})
+	if runErr == nil {
+		t.Error("got no error for an out of date snippet file")
+	}
+	if want := stale + "\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+	if after, err := os.ReadFile(stale); err != nil || string(after) != string(before) {
+		t.Errorf("-check rewrote %s: %v", stale, err)
+	}
+
+	os.Remove(filepath.Join(out, "python.json"))
+	stdout = captureStdout(t, func() {
+		runErr = run(t, "-o", out, "-check", filepath.Join(dir, "src"))
+	})
+	if want := stale + "\n" + filepath.Join(out, "python.json") + "\n"; runErr == nil || stdout != want {
+		t.Errorf("got %q, error %v, want %q listed for a missing file", stdout, runErr, want)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"64k", 64 << 10, false},
+		{"64K", 64 << 10, false},
+		{"2m", 2 << 20, false},
+		{"1g", 1 << 30, false},
+		{"", 0, true},
+		{"k", 0, true},
+		{"-1", 0, true},
+		{"1.5m", 0, true},
+		{"10kb", 0, true},
+	} {
+		var got ByteSize
+		err := got.Set(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.value, got, tt.want)
+		}
+	}
+	if got := ByteSize(64 << 10).String(); got != "64k" {
+		t.Errorf("got %q, want %q", got, "64k")
+	}
+}
+
+func TestMaxSizeFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/small.txt": strings.Repeat("a", 1024),
+		"src/big.txt":   strings.Repeat("a", 1025),
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-max-size", "1k", filepath.Join(dir, "src"))
+	if got := keys(t, filepath.Join(out, "txt.json")); !reflect.DeepEqual(got, []string{"small"}) {
+		t.Errorf("got %q, want only the file within the limit", got)
+	}
+}
+
+func TestOutputIsAFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"loop.go": "for {}\n",
+		"out":     "not a directory\n",
+	})
+	out := filepath.Join(dir, "out")
+	err := run(t, "-o", out, filepath.Join(dir, "loop.go"))
+	want := "output path " + out + " exists and is not a directory"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestExtFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  []string
+	}{
+		{"all", nil, []string{"go.json", "python.json", "typescript.json"}},
+		{"ext", []string{"-ext", "go,ts"}, []string{"go.json", "typescript.json"}},
+		{"exclude-ext", []string{"-exclude-ext", "py"}, []string{"go.json", "typescript.json"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"src/app.ts":  "export {}\n",
+				"src/main.py": "pass\n",
+				"src/main.go": "package main\n",
+			})
+			out := filepath.Join(dir, "out")
+			mustRun(t, append([]string{"-o", out, filepath.Join(dir, "src")}, tt.flags...)...)
+			entries, err := os.ReadDir(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForceFlag(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions do not apply to root")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"loop.go":     "for {}\n",
+		"out/go.json": "{}\n",
+	})
+	out := filepath.Join(dir, "out")
+	target := filepath.Join(out, "go.json")
+	if err := os.Chmod(target, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	err := run(t, "-o", out, filepath.Join(dir, "loop.go"))
+	if !errors.Is(err, snippet.ErrReadOnly) || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("got error %v, want %v suggesting -force", err, snippet.ErrReadOnly)
+	}
+	if got := keys(t, target); len(got) != 0 {
+		t.Errorf("got %q written without -force", got)
+	}
+
+	mustRun(t, "-o", out, "-force", filepath.Join(dir, "loop.go"))
+	if got := keys(t, target); !reflect.DeepEqual(got, []string{"loop"}) {
+		t.Errorf("got %q with -force", got)
+	}
+}
+
+func TestTransformFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "\n\n    for {\n        x()\n    }\n\n"})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-transform", "trim,dedent", filepath.Join(dir, "loop.go"))
+	if got, want := readSnippets(t, filepath.Join(out, "go.json"))["loop"].Body, (snippet.Body{"for {", "    x()", "}"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if err := run(t, "-o", out, "-transform", "trim,minify", filepath.Join(dir, "loop.go")); err == nil {
+		t.Error("got no error for an unknown transform")
+	}
+}
+
+func TestSnippetsDir(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		goos    string
+		edition string
+		home    string
+		env     map[string]string
+		want    string
+	}{
+		{"linux", "linux", "stable", "/home/u", nil, "/home/u/.config/Code/User/snippets"},
+		{"linux xdg", "linux", "stable", "/home/u", map[string]string{"XDG_CONFIG_HOME": "/xdg"}, "/xdg/Code/User/snippets"},
+		{"linux insiders", "linux", "insiders", "/home/u", nil, "/home/u/.config/Code - Insiders/User/snippets"},
+		{"darwin", "darwin", "stable", "/Users/u", nil, "/Users/u/Library/Application Support/Code/User/snippets"},
+		{"windows", "windows", "stable", "/u", map[string]string{"APPDATA": "/appdata"}, "/appdata/Code/User/snippets"},
+		{"windows without APPDATA", "windows", "stable", "/u", nil, "/u/AppData/Roaming/Code/User/snippets"},
+		{"portable", "linux", "stable", "/home/u", map[string]string{"VSCODE_PORTABLE": "/portable"}, "/portable/user-data/User/snippets"},
+		{"no home", "linux", "stable", "", nil, ""},
+		{"no home darwin", "darwin", "stable", "", nil, ""},
+		{"no home xdg", "linux", "stable", "", map[string]string{"XDG_CONFIG_HOME": "/xdg"}, "/xdg/Code/User/snippets"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippetsDir(tt.goos, tt.edition, filepath.FromSlash(tt.home), func(key string) string {
+				return filepath.FromSlash(tt.env[key])
+			})
+			if want := filepath.FromSlash(tt.want); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNoOutputDirectory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user config directory is located by HOME and XDG_CONFIG_HOME")
+	}
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("VSCODE_PORTABLE", "")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+	chdir(t, dir)
+	err := run(t, "loop.go")
+	if err == nil || !strings.Contains(err.Error(), "use -o") {
+		t.Errorf("got error %v, want one requiring -o", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d entries in the current directory, want nothing written", len(entries))
+	}
+	// -o and -stdout need no snippets folder.
+	mustRun(t, "-o", filepath.Join(dir, "out"), "loop.go")
+	captureStdout(t, func() {
+		mustRun(t, "-stdout", "loop.go")
+	})
+}
+
+func TestEditions(t *testing.T) {
+	base := map[string]string{
+		"windows": "/u/AppData/Roaming",
+		"darwin":  "/u/Library/Application Support",
+		"linux":   "/u/.config",
+	}
+	for goos, config := range base {
+		for edition, folder := range map[string]string{"stable": "Code", "insiders": "Code - Insiders"} {
+			got := snippetsDir(goos, edition, filepath.FromSlash("/u"), func(string) string { return "" })
+			if want := filepath.Join(filepath.FromSlash(config), folder, "User", "snippets"); got != want {
+				t.Errorf("%s %s: got %q, want %q", goos, edition, got, want)
+			}
+		}
+	}
+}
+
+func TestEditionFlag(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user config directory is located by XDG_CONFIG_HOME")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("VSCODE_PORTABLE", "")
+	writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+
+	mustRun(t, "-edition", "insiders", filepath.Join(dir, "loop.go"))
+	if got := keys(t, filepath.Join(dir, "config", "Code - Insiders", "User", "snippets", "go.json")); !reflect.DeepEqual(got, []string{"loop"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", "Code")); !os.IsNotExist(err) {
+		t.Errorf("-edition insiders wrote into the stable folder: %v", err)
+	}
+	// -o takes precedence.
+	mustRun(t, "-edition", "insiders", "-o", filepath.Join(dir, "out"), filepath.Join(dir, "loop.go"))
+	if _, err := os.Stat(filepath.Join(dir, "out", "go.json")); err != nil {
+		t.Error(err)
+	}
+	if err := run(t, "-edition", "nightly", filepath.Join(dir, "loop.go")); err == nil {
+		t.Error("got no error for an unknown edition")
+	}
+}
+
+func TestArchiveArgument(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	f, err := os.Create(filepath.Join(dir, "bundle.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{"go/retry.go": "for {}\n", "go/": ""} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, filepath.Join(dir, "bundle.zip"), filepath.Join(dir, "src"))
+	if got, want := keys(t, filepath.Join(out, "go.json")), []string{"loop", "retry"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := run(t, "-o", out, filepath.Join(dir, "missing.zip")); !errors.Is(err, snippet.ErrReadFailed) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrReadFailed)
+	}
+}
+
+func TestDedupBodiesFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/a/loop.go":    "for {}\n",
+		"src/b/forever.go": "for {}\n",
+	})
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-dedup-bodies", "merge", filepath.Join(dir, "src"))
+	want := snippet.Snippet{"forever": {Prefix: snippet.Prefix{"forever", "loop"}, Body: snippet.Body{"for {}"}}}
+	if got := readSnippets(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if err := run(t, "-o", out, "-dedup-bodies", "last", filepath.Join(dir, "src")); err == nil {
+		t.Error("got no error for an unknown policy")
+	}
+}
+
+func TestNameTemplateFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/go/http/client.go": "http.Get()\n",
+		"src/go/grpc/client.go": "grpc.Dial()\n",
+	})
+	src := filepath.Join(dir, "src")
+	for _, tt := range []struct {
+		sep  string
+		want []string
+	}{
+		{".", []string{"go.grpc.client", "go.http.client"}},
+		{"_", []string{"go_grpc_client", "go_http_client"}},
+	} {
+		out := filepath.Join(dir, "out"+tt.sep)
+		mustRun(t, "-o", out, "-name-template", "{relpath}", "-path-sep", tt.sep, "-strip-prefix", src, src)
+		if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-path-sep %q: got %q, want %q", tt.sep, got, tt.want)
+		}
+	}
+	if err := run(t, "-name-template", "{relpath}", "-path-sep", "", src); err == nil {
+		t.Error("got no error for an empty -path-sep")
+	}
+	if err := run(t, "-name-template", "{path}", src); err == nil {
+		t.Error("got no error for an unknown placeholder")
+	}
+}
+
+func TestPrefixWithExtFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"client.go": "http.Get()\n"})
+	for _, tt := range []struct {
+		flags []string
+		want  snippet.Prefix
+	}{
+		{nil, snippet.Prefix{"client"}},
+		{[]string{"-prefix-with-ext"}, snippet.Prefix{"client.go"}},
+		{[]string{"-prefix-with-ext", "-prefix", "{ext}:{name}"}, snippet.Prefix{"go:client"}},
+	} {
+		out := filepath.Join(dir, "out")
+		mustRun(t, append(append([]string{"-o", out}, tt.flags...), filepath.Join(dir, "client.go"))...)
+		if got := readSnippets(t, filepath.Join(out, "go.json"))["client"].Prefix; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestTrimTrailingWSFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "for {  \n\tx()\t \n}\n"})
+	for _, tt := range []struct {
+		flags []string
+		want  snippet.Body
+	}{
+		{nil, snippet.Body{"for {  ", "\tx()\t ", "}"}},
+		{[]string{"-trim-trailing-ws"}, snippet.Body{"for {", "\tx()", "}"}},
+	} {
+		out := filepath.Join(dir, "out")
+		mustRun(t, append(append([]string{"-o", out}, tt.flags...), filepath.Join(dir, "loop.go"))...)
+		if got := readSnippets(t, filepath.Join(out, "go.json"))["loop"].Body; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestMode(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  Mode
+		ok    bool
+	}{
+		{"0750", 0750, true},
+		{"750", 0750, true},
+		{"0600", 0600, true},
+		{"777", 0777, true},
+		{"0", 0, false},
+		{"1777", 0, false},
+		{"0800", 0, false},
+		{"rwx", 0, false},
+		{"", 0, false},
+	} {
+		var m Mode
+		err := m.Set(tt.value)
+		if (err == nil) != tt.ok || m != tt.want {
+			t.Errorf("Set(%q): got %#o, error %v, want %#o", tt.value, m, err, tt.want)
+		}
+	}
+	if got := Mode(0750).String(); got != "0750" {
+		t.Errorf("got %q", got)
+	}
+	if got := Mode(0).String(); got != "" {
+		t.Errorf("got %q unset", got)
+	}
+}
+
+func TestModeFlags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not enforced on windows")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	perm := func(name string) fs.FileMode {
+		t.Helper()
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	// The umask never masks the permissions of the owner.
+	out := filepath.Join(dir, "out", "snippets")
+	mustRun(t, "-o", out, "-dir-mode", "0700", "-file-mode", "0600", filepath.Join(dir, "src"))
+	if got := perm(out); got != 0700 {
+		t.Errorf("got directory mode %#o, want 0700", got)
+	}
+	if got := perm(filepath.Join(out, "go.json")); got != 0600 {
+		t.Errorf("got file mode %#o, want 0600", got)
+	}
+	out = filepath.Join(dir, "split")
+	mustRun(t, "-o", out, "-split", "-dir-mode", "0700", "-file-mode", "0600", filepath.Join(dir, "src"))
+	if got := perm(filepath.Join(out, "go")); got != 0700 {
+		t.Errorf("got -split directory mode %#o, want 0700", got)
+	}
+	if got := perm(filepath.Join(out, "go", "loop.json")); got != 0600 {
+		t.Errorf("got -split file mode %#o, want 0600", got)
+	}
+
+	// The umask can only remove permissions.
+	out = filepath.Join(dir, "default")
+	mustRun(t, "-o", out, filepath.Join(dir, "src"))
+	if got := perm(out); got&^0755 != 0 || got&0700 != 0700 {
+		t.Errorf("got default directory mode %#o", got)
+	}
+	if got := perm(filepath.Join(out, "go.json")); got&^0666 != 0 || got&0600 != 0600 {
+		t.Errorf("got default file mode %#o", got)
+	}
+
+	for _, flags := range [][]string{{"-dir-mode", "0999"}, {"-file-mode", "rw"}} {
+		if err := run(t, append(flags, filepath.Join(dir, "src"))...); err == nil {
+			t.Errorf("%q: got no error", flags)
+		}
+	}
+}
+
+func TestGroupByFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/http/client.go":  "http.Get()\n",
+		"src/http/server.py":  "serve()\n",
+		"src/db/sub/query.go": "db.Query()\n",
+	})
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-group-by", "dir", src)
+	for file, want := range map[string]snippet.Snippet{
+		"http.code-snippets": {
+			"client": {Prefix: snippet.Prefix{"client"}, Scope: "go", Body: snippet.Body{"http.Get()"}},
+			"server": {Prefix: snippet.Prefix{"server"}, Scope: "python", Body: snippet.Body{"serve()"}},
+		},
+		"db.code-snippets": {
+			"query": {Prefix: snippet.Prefix{"query"}, Scope: "go", Body: snippet.Body{"db.Query()"}},
+		},
+	} {
+		if got := readSnippets(t, filepath.Join(out, file)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", file, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "go.json")); !os.IsNotExist(err) {
+		t.Errorf("-group-by dir wrote go.json: %v", err)
+	}
+
+	// Each argument is a root of its own.
+	out = filepath.Join(dir, "roots")
+	mustRun(t, "-o", out, "-group-by", "dir", filepath.Join(src, "http"), filepath.Join(src, "db"))
+	if got := keys(t, filepath.Join(out, "sub.code-snippets")); !reflect.DeepEqual(got, []string{"query"}) {
+		t.Errorf("got %q", got)
+	}
+	if got := keys(t, filepath.Join(out, "http.code-snippets")); !reflect.DeepEqual(got, []string{"client", "server"}) {
+		t.Errorf("got %q", got)
+	}
+
+	for _, flags := range [][]string{
+		{"-group-by", "lang"},
+		{"-group-by", "dir", "-split"},
+		{"-group-by", "dir", "-single", "all"},
+		{"-group-by", "dir", "-out-name", "py=python"},
+	} {
+		if err := run(t, append(flags, src)...); err == nil {
+			t.Errorf("%q: got no error", flags)
+		}
+	}
+}
+
+func TestStrictFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/empty.go": "\n",
+		"src/loop.go":  "for {}\n",
+	})
+	src := filepath.Join(dir, "src")
+	mustRun(t, "-o", filepath.Join(dir, "lax"), src)
+
+	out := filepath.Join(dir, "strict")
+	if err := run(t, "-o", out, "-strict", src); !errors.Is(err, snippet.ErrInvalid) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrInvalid)
+	}
+	if _, err := os.Stat(filepath.Join(out, "go.json")); !os.IsNotExist(err) {
+		t.Errorf("-strict wrote invalid snippets: %v", err)
+	}
+}
+
+func TestContentMatchFlags(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":   "// snippet\nfor {}\n",
+		"src/main.go":   "package main\n",
+		"src/helper.go": "// snippet\n// draft\nhelp()\n",
+	})
+	src := filepath.Join(dir, "src")
+	for _, tt := range []struct {
+		flags []string
+		want  []string
+	}{
+		{nil, []string{"helper", "loop", "main"}},
+		{[]string{"-require-match", "// snippet"}, []string{"helper", "loop"}},
+		{[]string{"-exclude-match", "package "}, []string{"helper", "loop"}},
+		{[]string{"-require-match", "// snippet", "-exclude-match", "(?m)^// draft$"}, []string{"loop"}},
+	} {
+		out := filepath.Join(dir, "out")
+		os.RemoveAll(out)
+		mustRun(t, append(append([]string{"-o", out}, tt.flags...), src)...)
+		if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+	for _, flag := range []string{"-require-match", "-exclude-match"} {
+		if err := run(t, flag, "(", src); err == nil {
+			t.Errorf("%s: got no error for an invalid regexp", flag)
+		}
+	}
+}
+
+func TestValidateSchemaFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go": "for {}\n",
+		"out/go.json": `{"old": {"prefix": "old", "body": {"text": "old"}}}`,
+	})
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	err := run(t, "-o", out, "-merge", "-validate-schema", src)
+	if !errors.Is(err, snippet.ErrSchema) || !strings.Contains(err.Error(), "#/old/body: expected string or array, got object") {
+		t.Errorf("got error %v, want a %v error pointing at the body", err, snippet.ErrSchema)
+	}
+	if err := run(t, "-o", out, "-validate-schema", src); err == nil {
+		t.Error("got no error for -validate-schema without -merge")
+	}
+
+	writeFiles(t, dir, map[string]string{"out/go.json": `{"old": {"prefix": "old", "body": "old"}}`})
+	mustRun(t, "-o", out, "-merge", "-validate-schema", src)
+	if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"loop", "old"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDescTemplateFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"client.go": "http.Get()\n"})
+	pathName := filepath.Join(dir, "client.go")
+	mtime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)
+	if err := os.Chtimes(pathName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-desc-template", "from {name}.{ext}, modified {mtime}", "-desc-time-format", "2006-01-02", pathName)
+	if got, want := readSnippets(t, filepath.Join(out, "go.json"))["client"].Description, "from client.go, modified 2024-01-02"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := run(t, "-o", out, "-desc-template", "{modified}", pathName); err == nil {
+		t.Error("got no error for an unknown placeholder")
+	}
+}
+
+func TestContextFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"loop.go": "for {}\n"})
+	for _, tt := range []struct {
+		flags []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"-context", "editorLangId == go"}, "editorLangId == go"},
+	} {
+		out := filepath.Join(dir, "out")
+		mustRun(t, append(append([]string{"-o", out}, tt.flags...), filepath.Join(dir, "loop.go"))...)
+		b, err := os.ReadFile(filepath.Join(out, "go.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw map[string]map[string]interface{}
+		if err := json.Unmarshal(b, &raw); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := raw["loop"]["x-context"]
+		if tt.want == "" && ok || tt.want != "" && got != tt.want {
+			t.Errorf("%q: got x-context %v in %s", tt.flags, got, b)
+		}
+	}
+}
+
+func TestWrapFlag(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("x", 25)
+	writeFiles(t, dir, map[string]string{"long.txt": long + "\n"})
+	for _, tt := range []struct {
+		flags []string
+		want  snippet.Body
+	}{
+		{nil, snippet.Body{long}},
+		{[]string{"-wrap", "10"}, snippet.Body{strings.Repeat("x", 10) + `\`, strings.Repeat("x", 10) + `\`, strings.Repeat("x", 5)}},
+		{[]string{"-wrap", "10", "-wrap-marker", ""}, snippet.Body{strings.Repeat("x", 10), strings.Repeat("x", 10), strings.Repeat("x", 5)}},
+	} {
+		out := filepath.Join(dir, "out")
+		mustRun(t, append(append([]string{"-o", out}, tt.flags...), filepath.Join(dir, "long.txt"))...)
+		if got := readSnippets(t, filepath.Join(out, "txt.json"))["long"].Body; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+	if err := run(t, "-wrap", "-1", filepath.Join(dir, "long.txt")); err == nil {
+		t.Error("got no error for a negative -wrap")
+	}
+}
+
+func TestCheckWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	mustRun(t, "-o", out, src)
+	for _, flag := range []string{"-check", "-dry-run"} {
+		index, cache := filepath.Join(dir, "index"+flag), filepath.Join(dir, "cache"+flag)
+		captureStdout(t, func() {
+			mustRun(t, "-o", out, flag, "-index", index, "-cache", cache, src)
+		})
+		for _, fileName := range []string{index, cache} {
+			if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+				t.Errorf("%s wrote %s: %v", flag, fileName, err)
+			}
+		}
+	}
+}
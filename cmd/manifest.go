@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// readManifest returns the paths listed in the manifest fileName, one per
+// line. Blank lines and lines starting with # are skipped.
+func readManifest(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	defer f.Close()
+
+	var paths []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":    "for {}\n",
+		"src/retry.go":   "retry()\n",
+		"src/wait.go":    "select {}\n",
+		"extra/spawn.go": "go f()\n",
+		"manifest.txt":   "# Curated snippets.\nsrc/loop.go\n\n  src/retry.go  \n# src/wait.go\n",
+	})
+	chdir(t, dir)
+
+	mustRun(t, "-o", "out", "-from-file", "manifest.txt")
+	if got, want := keys(t, filepath.Join(dir, "out", "go.json")), []string{"loop", "retry"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The arguments are added to the listed files.
+	mustRun(t, "-o", "both", "-from-file", "manifest.txt", "extra")
+	if got, want := keys(t, filepath.Join(dir, "both", "go.json")), []string{"loop", "retry", "spawn"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := run(t, "-o", "out", "-from-file", "missing.txt"); err == nil {
+		t.Error("got no error for a missing manifest")
+	}
+	writeFiles(t, dir, map[string]string{"broken.txt": "src/missing.go\n"})
+	if err := run(t, "-o", "out", "-from-file", "broken.txt"); err == nil {
+		t.Error("got no error for a missing listed file")
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"manifest.txt": "\n# comment\na.go\r\n  b/c.py\n\n#d.go\n"})
+	got, err := readManifest(filepath.Join(dir, "manifest.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.go", "b/c.py"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
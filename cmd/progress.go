@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often the progress line is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// progress reports on LogOutput how many of the input files were read. A nil
+// progress reports nothing.
+type progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	done  int
+	total int
+	drawn time.Time
+}
+
+// newProgress returns the progress of reading total files, or nil unless
+// -progress is set and stdout is a terminal, so that redirected output is
+// never mixed with it.
+func newProgress(total int) *progress {
+	if !Progress || LogLevel == LevelQuiet || !isTerminal(os.Stdout) {
+		return nil
+	}
+	return &progress{w: LogOutput, total: total}
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// add counts a read file.
+func (p *progress) add() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if now := time.Now(); p.done == p.total || now.Sub(p.drawn) >= progressInterval {
+		fmt.Fprintf(p.w, "\r%d/%d files", p.done, p.total)
+		p.drawn = now
+	}
+}
+
+// finish ends the progress line.
+func (p *progress) finish() {
+	if p == nil || p.drawn.IsZero() {
+		return
+	}
+	fmt.Fprintln(p.w)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{w: &buf, total: 3}
+	for i := 0; i < 3; i++ {
+		p.add()
+	}
+	p.finish()
+	// The first file draws the line, the last one always does.
+	if got, want := buf.String(), "\r1/3 files\r3/3 files\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var none *progress
+	none.add()
+	none.finish()
+}
+
+func TestProgressNotATerminal(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	writeFiles(t, src, manyFiles(50))
+	args, err := setup(t, "-progress", "-stdout", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	LogOutput = &logged
+	// Stdout is a pipe, not a terminal.
+	stdout := captureStdout(t, func() {
+		if err := process(context.Background(), args); err != nil {
+			t.Error(err)
+		}
+	})
+	if !json.Valid([]byte(stdout)) {
+		t.Errorf("got invalid JSON %q", stdout)
+	}
+	if bytes.Contains(logged.Bytes(), []byte("files")) {
+		t.Errorf("got progress %q when stdout is not a terminal", logged.String())
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// source is an input file and its content. Under -cache, the file is stated
+// before reading it.
+type source struct {
+	pathName string
+	// root is the argument the file was found in, or its directory.
+	root    string
+	content []byte
+	read    bool
+	modTime time.Time
+	size    int64
+}
+
+// readSources reads the sources not read yet using Jobs concurrent workers,
+// reporting the progress under -progress. It returns the first error in
+// sources order.
+func readSources(ctx context.Context, sources []source) error {
+	errs := make([]error, len(sources))
+	next := make(chan int)
+	p := newProgress(len(sources))
+	defer p.finish()
+
+	var wg sync.WaitGroup
+	for w := 0; w < Jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				b, err := readFile(sources[i].pathName)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				sources[i].content, sources[i].read = b, true
+				p.add()
+			}
+		}()
+	}
+
+feed:
+	for i := range sources {
+		if sources[i].read {
+			p.add()
+			continue
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile returns the content of the file at pathName. Under -max-size, at
+// most one byte more than the limit is read, enough for AddReader to skip
+// the file, so that large files are never read whole.
+func readFile(pathName string) ([]byte, error) {
+	f, err := os.Open(pathName)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, pathName, err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if MaxSize > 0 {
+		r = io.LimitReader(f, int64(MaxSize)+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, pathName, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// manyFiles returns n files spread over a few directories and languages,
+// with colliding names.
+func manyFiles(n int) map[string]string {
+	exts := []string{"go", "py", "ts", "sh"}
+	files := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("dir%d/snippet%d.%s", i%7, i%50, exts[i%len(exts)])
+		files[name] = fmt.Sprintf("// snippet %d\nline %d\n", i, i)
+	}
+	return files
+}
+
+func TestParallelReadOutput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeFiles(t, src, manyFiles(400))
+
+	outputs := map[int]map[string][]byte{}
+	for _, jobs := range []int{1, 8} {
+		out := filepath.Join(dir, "out"+strconv.Itoa(jobs))
+		mustRun(t, "-o", out, "-jobs", strconv.Itoa(jobs), "-on-collision", "rename", "-desc-from", "firstline", src)
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[jobs] = map[string][]byte{}
+		for _, entry := range entries {
+			b, err := os.ReadFile(filepath.Join(out, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs[jobs][entry.Name()] = b
+		}
+	}
+	if len(outputs[1]) == 0 {
+		t.Fatal("no snippet files written")
+	}
+	if len(outputs[1]) != len(outputs[8]) {
+		t.Fatalf("got %d snippet files serially and %d in parallel", len(outputs[1]), len(outputs[8]))
+	}
+	for name, serial := range outputs[1] {
+		if !bytes.Equal(serial, outputs[8][name]) {
+			t.Errorf("%s differs between the serial and parallel reads", name)
+		}
+	}
+}
+
+func TestJobsFlag(t *testing.T) {
+	if err := run(t, "-o", t.TempDir(), "-jobs", "0", "."); err == nil {
+		t.Error("got no error for -jobs 0")
+	}
+}
+
+func BenchmarkReadSources(b *testing.B) {
+	dir := b.TempDir()
+	files := manyFiles(1000)
+	writeFiles(b, dir, files)
+	var paths []string
+	for name := range files {
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	for _, jobs := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			resetFlags(b)
+			Jobs = jobs
+			for i := 0; i < b.N; i++ {
+				sources := make([]source, len(paths))
+				for j, pathName := range paths {
+					sources[j] = source{pathName: pathName}
+				}
+				if err := readSources(context.Background(), sources); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// readRenames returns the OLD=NEW snippet name mapping of the -rename file
+// fileName, one pair per line. Blank lines and lines starting with # are
+// skipped.
+func readRenames(fileName string) (map[string]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	defer f.Close()
+
+	renames := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		old, name, ok := strings.Cut(line, "=")
+		old, name = strings.TrimSpace(old), strings.TrimSpace(name)
+		if !ok || old == "" || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected OLD=NEW, got %q", fileName, n, line)
+		}
+		renames[old] = name
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", snippet.ErrReadFailed, fileName, err)
+	}
+	return renames, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func TestReadRenames(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"pairs", "loop=for-loop\nretry = retry-forever\n", map[string]string{"loop": "for-loop", "retry": "retry-forever"}, false},
+		{"comments and blank lines", "# renames\n\n  \nloop=for-loop\n", map[string]string{"loop": "for-loop"}, false},
+		{"last wins", "loop=a\nloop=b\n", map[string]string{"loop": "b"}, false},
+		{"equals in name", "loop=a=b\n", map[string]string{"loop": "a=b"}, false},
+		{"empty", "", map[string]string{}, false},
+		{"no separator", "loop\n", nil, true},
+		{"no old name", "=loop\n", nil, true},
+		{"no new name", "loop=\n", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "renames")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readRenames(fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := readRenames(filepath.Join(t.TempDir(), "missing"))
+	if !errors.Is(err, snippet.ErrReadFailed) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrReadFailed)
+	}
+}
+
+func TestRenameFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"renames":      "loop=for-loop\nretry=wait\n",
+		"src/loop.go":  "for {}\n",
+		"src/retry.go": "retry()\n",
+		"src/wait.go":  "wait()\n",
+	})
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+	mustRun(t, "-o", out, "-rename", filepath.Join(dir, "renames"), "-on-collision", "rename", src)
+	if got, want := keys(t, filepath.Join(out, "go.json")), []string{"for-loop", "wait", "wait-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := run(t, "-o", out, "-rename", filepath.Join(dir, "renames"), "-on-collision", "error", src); !errors.Is(err, snippet.ErrCollision) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrCollision)
+	}
+	if err := run(t, "-o", out, "-rename", filepath.Join(dir, "missing"), src); !errors.Is(err, snippet.ErrReadFailed) {
+		t.Errorf("got error %v, want %v", err, snippet.ErrReadFailed)
+	}
+}
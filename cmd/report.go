@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// Report is the -report summary of a generation.
+type Report struct {
+	// Files is the number of files the snippets were generated from.
+	Files int `json:"files"`
+	// Snippets is the number of snippets generated, and Languages that
+	// number per language.
+	Snippets  int            `json:"snippets"`
+	Languages map[string]int `json:"languages"`
+	// Skipped lists the files left out by the extension, size, binary and
+	// content filters.
+	Skipped []skippedFile `json:"skipped"`
+	// Outputs lists the snippet files written, empty when writing to
+	// stdout.
+	Outputs []string `json:"outputs"`
+}
+
+// skippedFile is a file left out of the snippets, and why.
+type skippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// skipped collects the files skipped by the current generation.
+var skipped []skippedFile
+
+func skip(pathName, reason string) {
+	skipped = append(skipped, skippedFile{Path: pathName, Reason: reason})
+}
+
+// writeReport writes into fileName the report of snippets, whose outputs
+// were written into dir unless it is empty.
+func writeReport(fileName string, snippets *snippet.Snippets, outputs snippet.Outputs, dir string) error {
+	report := Report{
+		Files:     snippets.FileCount(),
+		Languages: map[string]int{},
+		Skipped:   append([]skippedFile{}, skipped...),
+		Outputs:   []string{},
+	}
+	for _, lang := range snippets.Langs() {
+		n := len(*snippets.Lang(lang))
+		report.Languages[lang] = n
+		report.Snippets += n
+	}
+	if dir != "" {
+		for _, name := range outputs.Names() {
+			report.Outputs = append(report.Outputs, filepath.Join(dir, name))
+		}
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	if err := os.WriteFile(fileName, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("%w %s: %w", snippet.ErrWriteFailed, fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":  "for {}\n",
+		"src/retry.go": "retry()\n",
+		"src/loop.py":  "while True: pass\n",
+		"src/app.ts":   "export {}\n",
+		"src/big.txt":  strings.Repeat("a", 2048),
+		"src/tool.bin": "\x7fELF\x00\x00",
+		"src/Makefile": "all:\n",
+	})
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	reportPath := filepath.Join(dir, "report.json")
+	mustRun(t, "-o", out, "-report", reportPath, "-max-size", "1k", "-exclude-ext", "ts", src)
+
+	b, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 3 || report.Snippets != 3 {
+		t.Errorf("got %d snippets from %d files, want 3 from 3", report.Snippets, report.Files)
+	}
+	if want := map[string]int{"go": 2, "python": 1}; !reflect.DeepEqual(report.Languages, want) {
+		t.Errorf("got languages %v, want %v", report.Languages, want)
+	}
+	if want := []string{filepath.Join(out, "go.json"), filepath.Join(out, "python.json")}; !reflect.DeepEqual(report.Outputs, want) {
+		t.Errorf("got outputs %q, want %q", report.Outputs, want)
+	}
+	skipped := map[string]string{}
+	for _, s := range report.Skipped {
+		skipped[filepath.Base(s.Path)] = s.Reason
+	}
+	want := map[string]string{
+		"app.ts":   "excluded extension",
+		"big.txt":  "larger than 1024 bytes",
+		"tool.bin": "binary",
+		"Makefile": "no extension",
+	}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("got skipped %q, want %q", skipped, want)
+	}
+
+	// Outputs are empty when writing to stdout.
+	captureStdout(t, func() {
+		mustRun(t, "-stdout", "-report", reportPath, src)
+	})
+	report = Report{}
+	b, err = os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Outputs == nil || len(report.Outputs) != 0 || report.Snippets != 5 || report.Files != 5 {
+		t.Errorf("got %+v with -stdout", report)
+	}
+}
+
+func TestReportFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loops.go": "=== a ===\n// snippet\na()\n=== b ===\n// snippet\nb()\n",
+		"src/retry.go": "=== retry ===\n// snippet\nretry()\n",
+		"src/empty.go": "// snippet, without sections\n",
+		"src/main.go":  "package main\n",
+	})
+	reportPath := filepath.Join(dir, "report.json")
+	mustRun(t, "-o", filepath.Join(dir, "out"), "-report", reportPath, "-split-on", "^=== (.+) ===$", "-require-match", "// snippet", filepath.Join(dir, "src"))
+	b, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 2 || report.Snippets != 3 {
+		t.Errorf("got %d snippets from %d files, want 3 from 2", report.Snippets, report.Files)
+	}
+	if want := []skippedFile{{Path: filepath.Join(dir, "src", "main.go"), Reason: "no content match"}}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("got skipped %+v, want %+v", report.Skipped, want)
+	}
+	if !strings.Contains(string(b), `"files": 2`) {
+		t.Errorf("got %s, want a files count", b)
+	}
+}
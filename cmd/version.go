@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// Build information, set with -ldflags "-X main.Version=... -X main.Commit=...
+// -X main.Date=...". Unset values are taken from the module build info.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+func buildInfo() (version, commit, date string) {
+	version, commit, date = Version, Commit, Date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && date == "":
+				date = setting.Value
+			}
+		}
+	}
+	if version == "" {
+		version = "(devel)"
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return version, commit, date
+}
+
+func printVersion(w io.Writer) {
+	version, commit, date := buildInfo()
+	fmt.Fprintf(w, "vscode-snippet-generator %s (commit %s, built %s)\n", version, commit, date)
+}
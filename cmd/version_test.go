@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	for _, tt := range []struct {
+		name                  string
+		version, commit, date string
+		want                  string
+	}{
+		{"ldflags", "v1.2.3", "abc123", "2024-01-02", "vscode-snippet-generator v1.2.3 (commit abc123, built 2024-01-02)\n"},
+		{"build info", "", "", "", "vscode-snippet-generator "},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := [3]string{Version, Commit, Date}
+			Version, Commit, Date = tt.version, tt.commit, tt.date
+			t.Cleanup(func() { Version, Commit, Date = saved[0], saved[1], saved[2] })
+
+			var buf bytes.Buffer
+			printVersion(&buf)
+			got := buf.String()
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			version, commit, date := buildInfo()
+			if version == "" || commit == "" || date == "" {
+				t.Errorf("got empty build info %q, %q, %q", version, commit, date)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walk walks root like filepath.Walk. With -follow-symlinks, symbolic links
+// to directories are walked too, under the path of the link. Every
+// directory is walked at most once, so links cannot cause cycles.
+func walk(root string, fn filepath.WalkFunc) error {
+	if !FollowSymlinks {
+		return filepath.Walk(root, fn)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFollow(root, info, fn, map[string]bool{})
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// depth returns how many directories below root pathName is: 0 for root
+// itself, 1 for the entries of root, and so on.
+func depth(root, pathName string) int {
+	rel, err := filepath.Rel(root, pathName)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return len(strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+func walkFollow(pathName string, info fs.FileInfo, fn filepath.WalkFunc, visited map[string]bool) error {
+	if !info.IsDir() {
+		return fn(pathName, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(pathName)
+	if err != nil {
+		return fn(pathName, info, err)
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+
+	if err := fn(pathName, info, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(pathName)
+	if err != nil {
+		if err := fn(pathName, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+	for _, entry := range entries {
+		child := filepath.Join(pathName, entry.Name())
+		childInfo, err := os.Stat(child)
+		if err != nil {
+			err = fn(child, nil, err)
+		} else {
+			err = walkFollow(child, childInfo, fn, visited)
+		}
+		if err == filepath.SkipDir {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup func(t *testing.T, src string) []string
+	}{
+		{"unreadable directory", func(t *testing.T, src string) []string {
+			if os.Geteuid() == 0 {
+				t.Skip("permissions do not apply to root")
+			}
+			locked := filepath.Join(src, "locked")
+			writeFiles(t, locked, map[string]string{"secret.go": "package secret\n"})
+			if err := os.Chmod(locked, 0); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(locked, 0755) })
+			return nil
+		}},
+		{"dangling link", func(t *testing.T, src string) []string {
+			if err := os.Symlink(filepath.Join(src, "missing"), filepath.Join(src, "dangling")); err != nil {
+				t.Skip(err)
+			}
+			return []string{"-follow-symlinks"}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			writeFiles(t, src, map[string]string{"loop.go": "for {}\n"})
+			flags := tt.setup(t, src)
+			out := filepath.Join(dir, "out")
+
+			if err := run(t, append([]string{"-o", out, src}, flags...)...); err == nil {
+				t.Error("got no error by default")
+			}
+			mustRun(t, append([]string{"-o", out, "-skip-errors", src}, flags...)...)
+			if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, []string{"loop"}) {
+				t.Errorf("go.json: got %q", got)
+			}
+		})
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/loop.go":        "for {}\n",
+		"shared/retry.go":    "for {}\n",
+		"shared/sub/wait.go": "select {}\n",
+	})
+	src := filepath.Join(dir, "src")
+	if err := os.Symlink(filepath.Join(dir, "shared"), filepath.Join(src, "shared")); err != nil {
+		t.Skip(err)
+	}
+	// A cycle back to the top of the walk.
+	if err := os.Symlink(src, filepath.Join(dir, "shared", "sub", "src")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  []string
+	}{
+		{"default", nil, []string{"loop"}},
+		{"follow", []string{"-follow-symlinks"}, []string{"loop", "retry", "wait"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			mustRun(t, append([]string{"-o", out, src}, tt.flags...)...)
+			if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDepth(t *testing.T) {
+	root := filepath.Join("src", "templates")
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{root, 0},
+		{filepath.Join(root, "a.go"), 1},
+		{filepath.Join(root, "go", "a.go"), 2},
+		{filepath.Join(root, "go", "sub", "a.go"), 3},
+	} {
+		if got := depth(root, tt.path); got != tt.want {
+			t.Errorf("depth(%q, %q) = %d, want %d", root, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDepthFlag(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/top.go":          "top()\n",
+		"src/a/one.go":        "one()\n",
+		"src/a/b/two.go":      "two()\n",
+		"src/a/b/c/three.go":  "three()\n",
+		"other/a/b/deeper.go": "deeper()\n",
+	})
+	for _, tt := range []struct {
+		depth string
+		want  []string
+	}{
+		{"-1", []string{"one", "three", "top", "two"}},
+		{"0", []string{"top"}},
+		{"1", []string{"one", "top"}},
+		{"2", []string{"one", "top", "two"}},
+		{"10", []string{"one", "three", "top", "two"}},
+	} {
+		out := filepath.Join(dir, "out"+tt.depth)
+		mustRun(t, "-o", out, "-max-depth", tt.depth, filepath.Join(dir, "src"))
+		if got := keys(t, filepath.Join(out, "go.json")); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-max-depth %s: got %q, want %q", tt.depth, got, tt.want)
+		}
+	}
+
+	// The depth is relative to each argument.
+	out := filepath.Join(dir, "roots")
+	mustRun(t, "-o", out, "-max-depth", "1", filepath.Join(dir, "src"), filepath.Join(dir, "other", "a"))
+	if got, want := keys(t, filepath.Join(out, "go.json")), []string{"deeper", "one", "top"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := run(t, "-max-depth", "-2", filepath.Join(dir, "src")); err == nil {
+		t.Error("got no error for -max-depth -2")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"time"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+// fileState identifies a version of a watched file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchedPaths returns the paths generate reads for args: the expanded
+// args and, with -from-file, the manifest and the files it lists.
+func watchedPaths(args []string) []string {
+	paths, _ := expandArgs(args)
+	if FromFile != "" {
+		paths = append(paths, FromFile)
+		if listed, err := readManifest(FromFile); err == nil {
+			paths = append(paths, listed...)
+		}
+	}
+	return paths
+}
+
+// scan returns the state of every file generate reads for args. Files that
+// vanish while scanning are ignored.
+func scan(args []string) map[string]fileState {
+	states := map[string]fileState{}
+	for _, pathName := range watchedPaths(args) {
+		walk(pathName, func(path string, info fs.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				states[path] = fileState{info.ModTime(), info.Size()}
+			}
+			return nil
+		})
+	}
+	return states
+}
+
+func sameStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !v.modTime.Equal(w.modTime) || v.size != w.size {
+			return false
+		}
+	}
+	return true
+}
+
+// encoded returns the encoding of the output name with opts, or nil if
+// there is no such output.
+func encoded(opts *snippet.Options, outputs snippet.Outputs, name string) []byte {
+	s, ok := outputs[name]
+	if !ok {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := opts.Encode(&buf, name, s); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// regenerate returns the snippets for args and their outputs.
+func regenerate(ctx context.Context, args []string) (*snippet.Snippets, snippet.Outputs, error) {
+	snippets, err := generate(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs, err := snippets.Outputs()
+	if err != nil {
+		return nil, nil, err
+	}
+	return snippets, outputs, nil
+}
+
+// watch polls the files read for args every WatchInterval and regenerates the
+// snippets once changes have settled for a full interval, rewriting only
+// the output files whose content changed, refreshing the -index, -report and
+// -lock files and then running -post-hook. It returns when ctx is done.
+func watch(ctx context.Context, args []string, snippets *snippet.Snippets) error {
+	opts := &snippets.Options
+	outputs, err := snippets.Outputs()
+	if err != nil {
+		return err
+	}
+	generated := scan(args)
+	last := generated
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := scan(args)
+		settled := sameStates(current, last)
+		last = current
+		if !settled || sameStates(current, generated) {
+			continue
+		}
+		generated = current
+
+		snippets, regenerated, err := regenerate(ctx, args)
+		if err != nil {
+			errorf("%v", err)
+			continue
+		}
+		written := 0
+		for _, name := range regenerated.Names() {
+			if bytes.Equal(encoded(opts, outputs, name), encoded(opts, regenerated, name)) {
+				continue
+			}
+			if err := opts.WriteFile(OutputDir, name, regenerated[name]); err != nil {
+				errorf("%v", err)
+				continue
+			}
+			written++
+		}
+		outputs = regenerated
+		if err := writeRecords(snippets, regenerated, OutputDir); err != nil {
+			errorf("%v", err)
+		}
+		if written > 0 {
+			if err := runHook(ctx, OutputDir); err != nil {
+				errorf("%v", err)
+			}
+		}
+	}
+}
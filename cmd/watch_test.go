@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	out := filepath.Join(dir, "out")
+	args, err := setup(t, "-o", out, "-watch", "-watch-interval", "10ms", filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- process(ctx, args) }()
+
+	fileName := filepath.Join(out, "go.json")
+	waitFor(t, func() bool {
+		b, err := os.ReadFile(fileName)
+		return err == nil && strings.Contains(string(b), "for {}")
+	})
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for i := 0; i < 10; i++ {}\n"})
+	waitFor(t, func() bool {
+		b, err := os.ReadFile(fileName)
+		return err == nil && strings.Contains(string(b), "i++")
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop when cancelled")
+	}
+}
+
+func TestWatchFromFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"listed/loop.go":  "for {}\n",
+		"listed/retry.go": "retry()\n",
+		"list":            filepath.Join(dir, "listed", "loop.go") + "\n",
+	})
+	out := filepath.Join(dir, "out")
+	args, err := setup(t, "-o", out, "-watch", "-watch-interval", "10ms", "-from-file", filepath.Join(dir, "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- process(ctx, args) }()
+
+	fileName := filepath.Join(out, "go.json")
+	contains := func(s string) func() bool {
+		return func() bool {
+			b, err := os.ReadFile(fileName)
+			return err == nil && strings.Contains(string(b), s)
+		}
+	}
+	waitFor(t, contains("for {}"))
+	// A listed file changes.
+	writeFiles(t, dir, map[string]string{"listed/loop.go": "for i := 0; i < 10; i++ {}\n"})
+	waitFor(t, contains("i++"))
+	// The manifest itself changes.
+	writeFiles(t, dir, map[string]string{"list": filepath.Join(dir, "listed", "retry.go") + "\n"})
+	waitFor(t, contains("retry()"))
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop when cancelled")
+	}
+}
+
+func TestWatchRecords(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/loop.go": "for {}\n"})
+	out := filepath.Join(dir, "out")
+	index, report, lock := filepath.Join(dir, "index"), filepath.Join(dir, "report"), filepath.Join(dir, "lock")
+	args, err := setup(t, "-o", out, "-watch", "-watch-interval", "10ms",
+		"-index", index, "-report", report, "-lock", lock, filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- process(ctx, args) }()
+
+	read := func(fileName string) string {
+		b, _ := os.ReadFile(fileName)
+		return string(b)
+	}
+	waitFor(t, func() bool { return strings.Contains(read(index), "loop") })
+	before := map[string]string{report: read(report), lock: read(lock)}
+	writeFiles(t, dir, map[string]string{"src/retry.go": "retry()\n"})
+	waitFor(t, func() bool { return strings.Contains(read(index), "retry") })
+	waitFor(t, func() bool { return read(report) != before[report] && read(lock) != before[lock] })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop when cancelled")
+	}
+}
+
+func TestWatchConflicts(t *testing.T) {
+	for _, flag := range []string{"-stdout", "-check", "-dry-run"} {
+		if err := run(t, "-o", t.TempDir(), "-watch", flag, t.TempDir()); err == nil {
+			t.Errorf("%s: got no error", flag)
+		}
+	}
+}
+
+func TestWatchStdin(t *testing.T) {
+	if err := run(t, "-o", t.TempDir(), "-watch", "-"); err == nil {
+		t.Error("got no error watching stdin")
+	}
+}
+
+func TestSameStates(t *testing.T) {
+	now := time.Now()
+	a := map[string]fileState{"a": {now, 1}}
+	for _, tt := range []struct {
+		name string
+		b    map[string]fileState
+		want bool
+	}{
+		{"same", map[string]fileState{"a": {now, 1}}, true},
+		{"resized", map[string]fileState{"a": {now, 2}}, false},
+		{"touched", map[string]fileState{"a": {now.Add(time.Second), 1}}, false},
+		{"added", map[string]fileState{"a": {now, 1}, "b": {now, 1}}, false},
+		{"removed", map[string]fileState{}, false},
+	} {
+		if got := sameStates(a, tt.b); got != tt.want {
+			t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// waitFor waits up to 5 seconds for cond to hold.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
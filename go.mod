@@ -1,5 +1,5 @@
 module vscode_snippet_generator
 
-go 1.12
+go 1.20
 
 require github.com/subosito/gotenv v1.4.2
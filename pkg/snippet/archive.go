@@ -0,0 +1,222 @@
+package snippet
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveExts are the extensions of the archives AddArchive reads.
+var ArchiveExts = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether pathName is named like an archive AddArchive
+// reads.
+func IsArchive(pathName string) bool {
+	for _, ext := range ArchiveExts {
+		if strings.HasSuffix(strings.ToLower(pathName), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddArchive adds the files in the zip or tar archive at pathName, gzipped
+// tars included, as AddFS does. Snippets are named after their path in the
+// archive.
+func (s *Snippets) AddArchive(pathName string) error {
+	if strings.HasSuffix(strings.ToLower(pathName), ".zip") {
+		zr, err := zip.OpenReader(pathName)
+		if err != nil {
+			return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+		}
+		defer zr.Close()
+		return s.AddFS(zr, ".")
+	}
+
+	f, err := os.Open(pathName)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(pathName), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	fsys, err := tarFS(r, s.Options.MaxSize)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+	}
+	return s.AddFS(fsys, ".")
+}
+
+// tarFS returns the regular files of the tar archive read from r, in
+// memory: tar archives can only be read sequentially. If maxSize is
+// positive, at most maxSize+1 bytes of every file are kept, enough for
+// AddFS to skip the larger ones.
+func tarFS(r io.Reader, maxSize int64) (fs.FS, error) {
+	fsys := memFS{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if hdr.Typeflag != tar.TypeReg || !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		var content io.Reader = tr
+		if maxSize > 0 {
+			content = io.LimitReader(tr, maxSize+1)
+		}
+		b, err := io.ReadAll(content)
+		if err != nil {
+			return nil, err
+		}
+		fsys.add(name, &memFile{
+			name:    path.Base(name),
+			data:    b,
+			size:    hdr.Size,
+			mode:    fs.FileMode(hdr.Mode).Perm(),
+			modTime: hdr.ModTime,
+		})
+	}
+}
+
+// memFS is an in-memory fs.FS of files by their path, their parent
+// directories implied.
+type memFS map[string]*memFile
+
+// memFile is a file of a memFS, or one of its directories without data.
+type memFile struct {
+	name    string
+	data    []byte
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f *memFile) Name() string               { return f.name }
+func (f *memFile) Size() int64                { return f.size }
+func (f *memFile) Mode() fs.FileMode          { return f.mode }
+func (f *memFile) ModTime() time.Time         { return f.modTime }
+func (f *memFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}           { return nil }
+func (f *memFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *memFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// add adds file at name to fsys, with its parent directories. A file
+// replaces any file of the same name, as later tar entries do.
+func (fsys memFS) add(name string, file *memFile) {
+	fsys[name] = file
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if _, ok := fsys[dir]; ok {
+			return
+		}
+		fsys[dir] = &memFile{name: path.Base(dir), mode: fs.ModeDir | 0555}
+		if dir == "." {
+			return
+		}
+	}
+}
+
+func (fsys memFS) Open(name string) (fs.File, error) {
+	file, ok := fsys.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &openMemFile{memFile: file, fsys: fsys, path: name}, nil
+}
+
+// ReadDir returns the entries of the directory name, sorted by name.
+func (fsys memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir, ok := fsys.lookup(name)
+	if !ok || !dir.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for p, file := range fsys {
+		if p != "." && path.Dir(p) == name {
+			entries = append(entries, file)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (fsys memFS) lookup(name string) (*memFile, bool) {
+	if !fs.ValidPath(name) {
+		return nil, false
+	}
+	if file, ok := fsys[name]; ok {
+		return file, true
+	}
+	// An empty archive still has its root directory.
+	if name == "." {
+		return &memFile{name: ".", mode: fs.ModeDir | 0555}, true
+	}
+	return nil, false
+}
+
+// openMemFile is an open memFile of fsys, read from offset. The entries
+// of a directory are read from dirOffset.
+type openMemFile struct {
+	*memFile
+	fsys      memFS
+	path      string
+	offset    int
+	dirOffset int
+}
+
+func (f *openMemFile) Stat() (fs.FileInfo, error) { return f.memFile, nil }
+func (f *openMemFile) Close() error               { return nil }
+
+func (f *openMemFile) Read(b []byte) (int, error) {
+	if f.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: errors.New("is a directory")}
+	}
+	if f.offset >= len(f.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+// ReadDir returns the next n entries of the directory f, as
+// fs.ReadDirFile does.
+func (f *openMemFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: errors.New("not a directory")}
+	}
+	entries, err := f.fsys.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	entries = entries[f.dirOffset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	f.dirOffset += len(entries)
+	return entries, nil
+}
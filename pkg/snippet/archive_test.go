@@ -0,0 +1,198 @@
+package snippet
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// archiveFiles are the entries of the test archives, in order.
+var archiveFiles = []struct{ name, content string }{
+	{"go/", ""},
+	{"go/retry.go", "for {}\n"},
+	{"python/loop.py", "while True:\n    pass\n"},
+	{"README", "not a snippet\n"},
+}
+
+func zipArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range archiveFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range archiveFiles {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), ModTime: time.Unix(0, 0)}
+		if f.name[len(f.name)-1] == '/' {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var archiveWant = map[string]Snippet{
+	"go":     {"retry": {Prefix: Prefix{"retry"}, Body: Body{"for {}"}}},
+	"python": {"loop": {Prefix: Prefix{"loop"}, Body: Body{"while True:", "    pass"}}},
+}
+
+func langSnippets(s *Snippets) map[string]Snippet {
+	got := map[string]Snippet{}
+	for _, lang := range s.Langs() {
+		got[lang] = *s.Lang(lang)
+	}
+	return got
+}
+
+func TestInMemoryZip(t *testing.T) {
+	b := zipArchive(t)
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(Options{})
+	if err := s.AddFS(zr, "."); err != nil {
+		t.Fatal(err)
+	}
+	if got := langSnippets(s); !reflect.DeepEqual(got, archiveWant) {
+		t.Errorf("got %+v, want %+v", got, archiveWant)
+	}
+}
+
+func TestAddArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		content func(t *testing.T) []byte
+	}{
+		{"snippets.zip", zipArchive},
+		{"snippets.tar", tarArchive},
+		{"snippets.tar.gz", func(t *testing.T) []byte { return gzipped(t, tarArchive(t)) }},
+		{"snippets.TGZ", func(t *testing.T) []byte { return gzipped(t, tarArchive(t)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathName := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(pathName, tt.content(t), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s := New(Options{})
+			if err := s.AddArchive(pathName); err != nil {
+				t.Fatal(err)
+			}
+			if got := langSnippets(s); !reflect.DeepEqual(got, archiveWant) {
+				t.Errorf("got %+v, want %+v", got, archiveWant)
+			}
+		})
+	}
+
+	for _, name := range []string{"missing.zip", "missing.tgz"} {
+		err := New(Options{}).AddArchive(filepath.Join(t.TempDir(), name))
+		if !errors.Is(err, ErrReadFailed) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrReadFailed)
+		}
+	}
+	corrupt := filepath.Join(t.TempDir(), "corrupt.tar.gz")
+	if err := os.WriteFile(corrupt, []byte("not gzipped"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(Options{}).AddArchive(corrupt); !errors.Is(err, ErrReadFailed) {
+		t.Errorf("got error %v, want %v", err, ErrReadFailed)
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.zip":    true,
+		"a.ZIP":    true,
+		"a.tar":    true,
+		"a.tar.gz": true,
+		"a.tgz":    true,
+		"a.gz":     false,
+		"a.go":     false,
+		"zip":      false,
+	} {
+		if got := IsArchive(name); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestTarFS(t *testing.T) {
+	fsys, err := tarFS(bytes.NewReader(tarArchive(t)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "go/retry.go", "python/loop.py", "README"); err != nil {
+		t.Error(err)
+	}
+
+	// Files larger than maxSize keep their size but only maxSize+1 bytes.
+	fsys, err = tarFS(bytes.NewReader(tarArchive(t)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := fsys.(memFS)["python/loop.py"]
+	if want := len(archiveFiles[2].content); file.size != int64(want) || string(file.data) != "while" {
+		t.Errorf("got %d bytes %q", file.size, file.data)
+	}
+	s := New(Options{MaxSize: 4})
+	if err := s.AddFS(fsys, "."); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Langs(); len(got) != 0 {
+		t.Errorf("got %q, want the large files skipped", got)
+	}
+
+	empty, err := tarFS(bytes.NewReader(nil), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(empty); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,238 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Body holds the lines of a snippet body. It is kept split so that bodies
+// read back from existing snippet files round-trip unchanged.
+type Body []string
+
+// NewBody returns the body for the file content b.
+func (o *Options) NewBody(b []byte) Body {
+	if !o.PreserveCRLF {
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	}
+	if o.Escape {
+		b = escape(b)
+	}
+	if o.TabstopMarker != nil {
+		b = tabstops(o.TabstopMarker, b)
+	}
+	text := strings.TrimRight(string(b), "\n")
+	lines := strings.Split(text, "\n")
+	if o.TrimBlankLines {
+		lines = trimBlankLines(lines)
+	}
+	if o.KeepTrailingNewline && len(text) < len(b) {
+		lines = append(lines, "")
+	}
+	if o.ExpandTabs > 0 {
+		for i, line := range lines {
+			lines[i] = expandTabs(line, o.ExpandTabs)
+		}
+	}
+	if o.TrimTrailingSpace {
+		for i, line := range lines {
+			lines[i] = trimTrailingSpace(line)
+		}
+	}
+	if o.Dedent {
+		lines = dedent(lines)
+	}
+	if o.Wrap > 0 {
+		lines = wrap(lines, o.Wrap, o.WrapMarker)
+	}
+	return lines
+}
+
+// wrap splits the lines longer than width characters into pieces of at
+// most width characters, each ending with marker but the last. Escapes,
+// tabstops, placeholders and variables are never split: a piece ends
+// before them if they do not fit, and one longer than width is a piece of
+// its own.
+func wrap(lines []string, width int, marker string) []string {
+	wrapped := make([]string, 0, len(lines))
+	for _, line := range lines {
+		runes := []rune(line)
+		start, n := 0, 0
+		for i := 0; i < len(runes); i += n {
+			n = tokenLen(runes[i:])
+			if i+n-start > width && i > start {
+				wrapped = append(wrapped, string(runes[start:i])+marker)
+				start = i
+			}
+		}
+		wrapped = append(wrapped, string(runes[start:]))
+	}
+	return wrapped
+}
+
+// tokenLen returns the length of the snippet syntax element runes starts
+// with: an escape, a $N tabstop, a $name variable, a ${...} placeholder or
+// variable, nested ones included, or else a single character.
+func tokenLen(runes []rune) int {
+	word := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	switch {
+	case len(runes) < 2:
+		return len(runes)
+	case runes[0] == '\\':
+		return 2
+	case runes[0] != '$':
+		return 1
+	case runes[1] == '{':
+		depth := 0
+		for i := 1; i < len(runes); i++ {
+			switch {
+			case runes[i] == '\\':
+				i++
+			case runes[i] == '{':
+				depth++
+			case runes[i] == '}':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		// An unclosed placeholder is not one.
+		return 1
+	}
+	// $1 is a tabstop; $TM_FILENAME, a variable.
+	in := word
+	if unicode.IsDigit(runes[1]) {
+		in = unicode.IsDigit
+	}
+	n := 1
+	for n < len(runes) && in(runes[n]) {
+		n++
+	}
+	return n
+}
+
+// dedent removes from lines the leading whitespace common to all the lines
+// that are not blank. Tabs and spaces are not interchangeable: a tab only
+// matches a tab, so that mixed indentation is never broken.
+func dedent(lines []string) []string {
+	common, found := "", false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			common, found = indent, true
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(indent) && common[n] == indent[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if common == "" {
+		return lines
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, common)
+	}
+	return lines
+}
+
+// trimTrailingSpace returns line without its trailing spaces and tabs. The
+// carriage return of a preserved CRLF line ending is kept.
+func trimTrailingSpace(line string) string {
+	if strings.HasSuffix(line, "\r") {
+		return strings.TrimRight(line[:len(line)-1], " \t") + "\r"
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// trimBlankLines returns lines without its leading and trailing lines made
+// only of whitespace. A blank body is left with a single empty line, as an
+// empty file.
+func trimBlankLines(lines []string) []string {
+	blank := func(line string) bool { return strings.TrimSpace(line) == "" }
+	for len(lines) > 0 && blank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []string{""}
+	}
+	return lines
+}
+
+// expandTabs replaces the tabs in line with spaces up to the next multiple
+// of width columns.
+func expandTabs(line string, width int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var sb strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		sb.WriteRune(r)
+		col++
+	}
+	return sb.String()
+}
+
+// tabstops rewrites the matches of marker in b into $N tabstops. Matches
+// whose capture is not a number are left untouched.
+func tabstops(marker *regexp.Regexp, b []byte) []byte {
+	return marker.ReplaceAllFunc(b, func(m []byte) []byte {
+		n, err := strconv.Atoi(string(marker.FindSubmatch(m)[1]))
+		if err != nil || n < 0 {
+			return m
+		}
+		return []byte("$" + strconv.Itoa(n))
+	})
+}
+
+// escape escapes the characters with a meaning in the snippet grammar.
+// Characters already escaped with a backslash are left untouched.
+func escape(b []byte) []byte {
+	special := func(c byte) bool { return c == '$' || c == '}' || c == '\\' }
+	escaped := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '\\' && i+1 < len(b) && special(b[i+1]):
+			escaped = append(escaped, b[i], b[i+1])
+			i++
+		case special(b[i]):
+			escaped = append(escaped, '\\', b[i])
+		default:
+			escaped = append(escaped, b[i])
+		}
+	}
+	return escaped
+}
+
+// UnmarshalJSON accepts a body either as an array of lines or as a single
+// string, as VS Code does.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = strings.Split(text, "\n")
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*b = lines
+	return nil
+}
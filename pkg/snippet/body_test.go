@@ -0,0 +1,95 @@
+package snippet
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestNewBody(t *testing.T) {
+	marker := regexp.MustCompile(`%%(\d+)%%`)
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		content string
+		want    Body
+	}{
+		{"lines", Options{}, "a\nb\n", Body{"a", "b"}},
+		{"no escape", Options{}, "echo $HOME\n", Body{"echo $HOME"}},
+		{"escape variable", Options{Escape: true}, "echo $HOME\n", Body{`echo \$HOME`}},
+		{"escape braces", Options{Escape: true}, "${VAR}\n", Body{`\${VAR\}`}},
+		{"escape backslash", Options{Escape: true}, `a\b` + "\n", Body{`a\\b`}},
+		{"escape escaped", Options{Escape: true}, `\$HOME \} \\` + "\n", Body{`\$HOME \} \\`}},
+		{"tabstops", Options{TabstopMarker: marker}, "for %%1%% := range %%2%% {\n\t%%0%%\n}\n", Body{"for $1 := range $2 {", "\t$0", "}"}},
+		{"repeated tabstop", Options{TabstopMarker: marker}, "%%1%% = %%1%% + 1\n", Body{"$1 = $1 + 1"}},
+		{"dollars kept", Options{TabstopMarker: marker}, "echo $HOME %%1%%\n", Body{"echo $HOME $1"}},
+		{"not a number", Options{TabstopMarker: regexp.MustCompile(`<(\w+)>`)}, "<1> <x>\n", Body{"$1 <x>"}},
+		{"trailing newline trimmed", Options{}, "a\n", Body{"a"}},
+		{"trailing newlines trimmed", Options{}, "a\n\n\n", Body{"a"}},
+		{"trailing newline kept", Options{KeepTrailingNewline: true}, "a\n", Body{"a", ""}},
+		{"trailing newlines kept once", Options{KeepTrailingNewline: true}, "a\n\n\n", Body{"a", ""}},
+		{"no trailing newline", Options{KeepTrailingNewline: true}, "a", Body{"a"}},
+		{"crlf", Options{}, "a\r\nb\r\n", Body{"a", "b"}},
+		{"mixed endings", Options{}, "a\r\nb\nc", Body{"a", "b", "c"}},
+		{"crlf preserved", Options{PreserveCRLF: true}, "a\r\nb\r\n", Body{"a\r", "b\r"}},
+		{"lone carriage return", Options{}, "a\rb\n", Body{"a\rb"}},
+		{"tabs kept", Options{}, "\tx\n", Body{"\tx"}},
+		{"tabs expanded", Options{ExpandTabs: 4}, "\tx\n  \ty\n", Body{"    x", "    y"}},
+		{"tab stops", Options{ExpandTabs: 4}, "ab\tc\tdefg\th\n", Body{"ab  c   defg    h"}},
+		{"mixed indentation", Options{ExpandTabs: 2}, "\t \tx\n", Body{"    x"}},
+		{"wide runes", Options{ExpandTabs: 4}, "\u00e9\tx\n", Body{"\u00e9   x"}},
+		{"blank lines kept", Options{}, "\n\n  \na\n\nb\n\n", Body{"", "", "  ", "a", "", "b"}},
+		{"blank lines trimmed", Options{TrimBlankLines: true}, "\n\n  \na\n\nb\n \t\n\n", Body{"a", "", "b"}},
+		{"crlf blank lines trimmed", Options{TrimBlankLines: true}, "\r\n\r\na\r\n\r\n", Body{"a"}},
+		{"only blank lines", Options{TrimBlankLines: true}, "\n \n\n", Body{""}},
+		{"not dedented", Options{}, "\t\tif x {\n\t\t\ty()\n\t\t}\n", Body{"\t\tif x {", "\t\t\ty()", "\t\t}"}},
+		{"dedented", Options{Dedent: true}, "\t\tif x {\n\t\t\ty()\n\t\t}\n", Body{"if x {", "\ty()", "}"}},
+		{"dedented spaces", Options{Dedent: true}, "    a\n      b\n    c\n", Body{"a", "  b", "c"}},
+		{"dedent non-uniform", Options{Dedent: true}, "      a\n  b\n    c\n", Body{"    a", "b", "  c"}},
+		{"dedent ignores blank lines", Options{Dedent: true}, "    a\n\n  \n      \n    b\n", Body{"a", "", "  ", "  ", "b"}},
+		{"dedent mixed prefixes", Options{Dedent: true}, "\t  a\n\t b\n", Body{" a", "b"}},
+		{"dedent differing whitespace", Options{Dedent: true}, "\ta\n    b\n", Body{"\ta", "    b"}},
+		{"trailing spaces kept", Options{}, "a  \n\tb\t\n", Body{"a  ", "\tb\t"}},
+		{"trailing spaces trimmed", Options{TrimTrailingSpace: true}, "a  \n\tb\t \t\n  \n", Body{"a", "\tb", ""}},
+		{"leading indentation kept", Options{TrimTrailingSpace: true}, "\t  x  \n    y\t\n", Body{"\t  x", "    y"}},
+		{"trailing spaces trimmed crlf", Options{TrimTrailingSpace: true, PreserveCRLF: true}, "a \t\r\nb\r\n", Body{"a\r", "b\r"}},
+		{"trailing expanded tabs trimmed", Options{TrimTrailingSpace: true, ExpandTabs: 4}, "a\t\n", Body{"a"}},
+		{"not wrapped", Options{}, "abcdefghij\n", Body{"abcdefghij"}},
+		{"wrapped", Options{Wrap: 4, WrapMarker: `\`}, "abcdefghij\n", Body{`abcd\`, `efgh\`, "ij"}},
+		{"wrapped exactly", Options{Wrap: 5, WrapMarker: `\`}, "abcdefghij\n", Body{`abcde\`, "fghij"}},
+		{"short lines kept", Options{Wrap: 4, WrapMarker: `\`}, "ab\n\nabcd\n", Body{"ab", "", "abcd"}},
+		{"no marker", Options{Wrap: 3}, "abcdefg\n", Body{"abc", "def", "g"}},
+		{"wrapped runes", Options{Wrap: 2, WrapMarker: "+"}, "éèê\n", Body{"éè+", "ê"}},
+		{"wrapped after tabs", Options{Wrap: 4, ExpandTabs: 4, WrapMarker: `\`}, "\tab\n", Body{`    \`, "ab"}},
+		{"escapes not split", Options{Wrap: 4, WrapMarker: "+", Escape: true}, "a$b}c$d\n", Body{`a\$b+`, `\}c+`, `\$d`}},
+		{"tabstops not split", Options{Wrap: 4, WrapMarker: "+", TabstopMarker: marker}, "x = %%12%% + %%3%%\n", Body{"x = +", "$12 +", "+ $3"}},
+		{"placeholders not split", Options{Wrap: 6, WrapMarker: "+"}, "f(${1:name}, ${2:${3:x}})\n", Body{"f(+", "${1:name}+", ", +", "${2:${3:x}}+", ")"}},
+		{"variables not split", Options{Wrap: 4, WrapMarker: "+"}, "ab $TM_FILENAME $1x\n", Body{"ab +", "$TM_FILENAME+", " $1x"}},
+		{"escaped braces in placeholders", Options{Wrap: 4, WrapMarker: "+"}, `${1:a\}b}c` + "\n", Body{`${1:a\}b}+`, "c"}},
+		{"unclosed placeholder", Options{Wrap: 3, WrapMarker: "+"}, "${1:abc\n", Body{"${1+", ":ab+", "c"}},
+		{"dollar-heavy line", Options{Wrap: 5, WrapMarker: "+", Escape: true}, "$$$$$$\n", Body{`\$\$+`, `\$\$+`, `\$\$`}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.NewBody([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandTabs(t *testing.T) {
+	for _, tt := range []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"\t", 8, "        "},
+		{"a\tb", 8, "a       b"},
+		{"1234\t5", 4, "1234    5"},
+		{"no tabs", 4, "no tabs"},
+	} {
+		if got := expandTabs(tt.line, tt.width); got != tt.want {
+			t.Errorf("expandTabs(%q, %d): got %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package snippet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OwnedFilesName is the file listing, under Clean, the snippet files Write
+// wrote into a directory, one name per line.
+const OwnedFilesName = ".snippetgen-files"
+
+// ownedFiles returns the names listed in the OwnedFilesName file of dir.
+// Names that are not local to dir are ignored, so a tampered list never
+// removes files outside of it.
+func ownedFiles(dir string) ([]string, error) {
+	fileName := filepath.Join(dir, OwnedFilesName)
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+	}
+	defer f.Close()
+
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name := filepath.FromSlash(strings.TrimSpace(sc.Text()))
+		if name == "" || !filepath.IsLocal(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+	}
+	return names, nil
+}
+
+// clean removes the snippet files of dir written by a previous Write that
+// outputs no longer has. Files not written by Write are never removed.
+func (o *Options) clean(dir string, outputs Outputs) error {
+	owned, err := ownedFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range owned {
+		if _, ok := outputs[name]; ok {
+			continue
+		}
+		fileName := filepath.Join(dir, name)
+		if err := os.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w %s: removing: %w", ErrWriteFailed, fileName, err)
+		}
+		o.verbosef("removed %s", fileName)
+		// The LANG directories of Split outputs go with their last file.
+		if sub := filepath.Dir(name); sub != "." {
+			os.Remove(filepath.Join(dir, sub))
+		}
+	}
+	return nil
+}
+
+// writeOwnedFiles records the names of outputs as the files Write owns in
+// dir.
+func (o *Options) writeOwnedFiles(dir string, outputs Outputs) error {
+	var sb strings.Builder
+	for _, name := range outputs.Names() {
+		sb.WriteString(filepath.ToSlash(name) + "\n")
+	}
+	fileName := filepath.Join(dir, OwnedFilesName)
+	if err := os.WriteFile(fileName, []byte(sb.String()), o.fileMode()); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrWriteFailed, fileName, err)
+	}
+	return nil
+}
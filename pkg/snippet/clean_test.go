@@ -0,0 +1,101 @@
+package snippet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// listFiles returns the paths of the files under dir, sorted, with /
+// separators.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(pathName string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, pathName)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestClean(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, map[string]string{
+		"manual.json":   `{"manual": {"prefix": "manual", "body": ["hand"]}}`,
+		"settings.json": "{}",
+	})
+	write := func(opts Options, files map[string]string) {
+		t.Helper()
+		s, _ := addFiles(t, opts, files)
+		if err := s.Write(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := Options{Clean: true}
+	write(opts, map[string]string{"loop.go": "for {}\n", "loop.py": "pass\n", "loop.sh": "true\n"})
+	want := []string{OwnedFilesName, "go.json", "manual.json", "python.json", "settings.json", "shellscript.json"}
+	if got := listFiles(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The python and shell sources were removed.
+	write(opts, map[string]string{"loop.go": "for {}\n"})
+	want = []string{OwnedFilesName, "go.json", "manual.json", "settings.json"}
+	if got := listFiles(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Under Split, the go.json written before is no longer generated, and
+	// LANG directories go with their last file.
+	write(Options{Clean: true, Split: true}, map[string]string{"loop.go": "for {}\n", "loop.py": "pass\n"})
+	write(Options{Clean: true, Split: true}, map[string]string{"loop.go": "for {}\n"})
+	want = []string{OwnedFilesName, "go/loop.json", "manual.json", "settings.json"}
+	if got := listFiles(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "python")); !os.IsNotExist(err) {
+		t.Errorf("python directory kept: %v", err)
+	}
+}
+
+func TestCleanTamperedList(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	writeFiles(t, dir, map[string]string{
+		"outside.json":          "{}",
+		"out/" + OwnedFilesName: "../outside.json\n" + filepath.Join(dir, "outside.json") + "\nstale.json\n",
+		"out/stale.json":        "{}",
+	})
+	s, _ := addFiles(t, Options{Clean: true}, map[string]string{"loop.go": "for {}\n"})
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.json")); err != nil {
+		t.Errorf("removed a file outside of the output directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "stale.json")); !os.IsNotExist(err) {
+		t.Errorf("stale.json kept: %v", err)
+	}
+}
+
+func TestCleanNothingGenerated(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, map[string]string{OwnedFilesName: "go.json\n", "go.json": "{}"})
+	if err := New(Options{Clean: true}).Write(context.Background(), out); err == nil {
+		t.Error("got no error cleaning without snippets")
+	}
+	if _, err := os.Stat(filepath.Join(out, "go.json")); err != nil {
+		t.Errorf("go.json removed: %v", err)
+	}
+}
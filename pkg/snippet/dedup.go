@@ -0,0 +1,57 @@
+package snippet
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// lang returns the snippets of language lang as they are written: with
+// the snippets with identical bodies deduplicated according to DedupBodies.
+func (s *Snippets) lang(lang string) *Snippet {
+	snippet := s.langs[lang]
+	if s.Options.DedupBodies == "" {
+		return snippet
+	}
+	return dedup(snippet, s.Options.DedupBodies == "merge")
+}
+
+// dedup returns a copy of snippet keeping, among the snippets with
+// identical bodies, only the first one in key order. With merge, the one
+// kept gets the prefixes of the others too.
+func dedup(snippet *Snippet, merge bool) *Snippet {
+	deduped := make(Snippet, len(*snippet))
+	first := map[[sha256.Size]byte]string{}
+	for _, k := range snippet.Keys() {
+		file := (*snippet)[k]
+		sum := sha256.Sum256([]byte(strings.Join(file.Body, "\n")))
+		kept, ok := first[sum]
+		if !ok {
+			first[sum] = k
+			deduped[k] = file
+			continue
+		}
+		if merge {
+			f := *deduped[kept]
+			f.Prefix = appendNew(append(Prefix{}, f.Prefix...), file.Prefix)
+			deduped[kept] = &f
+		}
+	}
+	return &deduped
+}
+
+// appendNew appends to prefix the items of more it does not have.
+func appendNew(prefix, more Prefix) Prefix {
+	for _, p := range more {
+		found := false
+		for _, q := range prefix {
+			if p == q {
+				found = true
+				break
+			}
+		}
+		if !found {
+			prefix = append(prefix, p)
+		}
+	}
+	return prefix
+}
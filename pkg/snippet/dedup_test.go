@@ -0,0 +1,72 @@
+package snippet
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDedupBodies(t *testing.T) {
+	files := map[string]string{
+		"a/loop.go":    "for {}\n",
+		"b/forever.go": "for {}\n",
+		"c/retry.go":   "retry()\n",
+		"d/again.go":   "retry()\n",
+		"e/loop.py":    "for {}\n",
+	}
+	tests := []struct {
+		policy string
+		want   Snippet
+	}{
+		{"", Snippet{
+			"loop":    {Prefix: Prefix{"loop"}, Body: Body{"for {}"}},
+			"forever": {Prefix: Prefix{"forever"}, Body: Body{"for {}"}},
+			"retry":   {Prefix: Prefix{"retry"}, Body: Body{"retry()"}},
+			"again":   {Prefix: Prefix{"again"}, Body: Body{"retry()"}},
+		}},
+		{"first", Snippet{
+			"forever": {Prefix: Prefix{"forever"}, Body: Body{"for {}"}},
+			"again":   {Prefix: Prefix{"again"}, Body: Body{"retry()"}},
+		}},
+		{"merge", Snippet{
+			"forever": {Prefix: Prefix{"forever", "loop"}, Body: Body{"for {}"}},
+			"again":   {Prefix: Prefix{"again", "retry"}, Body: Body{"retry()"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s, dir := addFiles(t, Options{DedupBodies: tt.policy}, files)
+			if err := s.Write(context.Background(), dir); err != nil {
+				t.Fatal(err)
+			}
+			if got := readSnippets(t, filepath.Join(dir, "go.json")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			// Only snippets of the same language are deduplicated.
+			if got := readSnippets(t, filepath.Join(dir, "python.json")); len(got) != 1 {
+				t.Errorf("got %+v", got)
+			}
+			// The snippets added are left as they are.
+			if got := len(*s.Lang("go")); got != 4 {
+				t.Errorf("got %d snippets added, want 4", got)
+			}
+		})
+	}
+}
+
+func TestAppendNew(t *testing.T) {
+	tests := []struct {
+		prefix, more, want Prefix
+	}{
+		{Prefix{"a"}, Prefix{"b"}, Prefix{"a", "b"}},
+		{Prefix{"a", "b"}, Prefix{"b", "c", "a"}, Prefix{"a", "b", "c"}},
+		{nil, Prefix{"a"}, Prefix{"a"}},
+		{Prefix{"a"}, nil, Prefix{"a"}},
+	}
+	for _, tt := range tests {
+		if got := appendNew(tt.prefix, tt.more); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("appendNew(%q, %q) = %q, want %q", tt.prefix, tt.more, got, tt.want)
+		}
+	}
+}
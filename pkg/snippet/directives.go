@@ -0,0 +1,61 @@
+package snippet
+
+import (
+	"bytes"
+	"strings"
+)
+
+// MetadataKeys are the snippet fields frontmatter and directives can set.
+var MetadataKeys = []string{"prefix", "description", "scope", "isFileTemplate"}
+
+// parseDirectives returns the metadata set by the @name: value directives
+// of Directives in the comments leading b, the file at pathName, and b
+// without them. Only the comments and blank lines at the top of b are
+// searched; unknown directives are reported and left in place.
+func (o *Options) parseDirectives(pathName string, b []byte) (*frontmatter, []byte) {
+	if len(o.Directives) == 0 {
+		return nil, b
+	}
+	var fm *frontmatter
+	var kept []byte
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for i, line := range lines {
+		text, ok := commentText(string(line))
+		if !ok {
+			if trimLine(line) != "" {
+				kept = append(kept, bytes.Join(lines[i:], nil)...)
+				break
+			}
+			kept = append(kept, line...)
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(text, "@"), ":")
+		if !strings.HasPrefix(text, "@") || !ok {
+			kept = append(kept, line...)
+			continue
+		}
+		field, known := o.Directives[strings.TrimSpace(name)]
+		if !known {
+			o.warnf("%s: unknown directive @%s", pathName, strings.TrimSpace(name))
+			kept = append(kept, line...)
+			continue
+		}
+		if fm == nil {
+			fm = &frontmatter{}
+		}
+		prefix := fm.Prefix
+		if !fm.set(field + ":" + value) {
+			o.warnf("%s: invalid directive @%s: %s", pathName, strings.TrimSpace(name), strings.TrimSpace(value))
+			kept = append(kept, line...)
+			continue
+		}
+		// Repeated prefix directives add prefixes.
+		if field == "prefix" && prefix != nil {
+			fm.Prefix = append(prefix, fm.Prefix...)
+		}
+	}
+	if fm == nil {
+		return nil, b
+	}
+	return fm, kept
+}
@@ -0,0 +1,58 @@
+package snippet
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testLogger records the warnings and summaries of a generation.
+type testLogger struct {
+	warnings []string
+	infos    []string
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Verbosef(format string, args ...interface{}) {}
+
+func TestDirectives(t *testing.T) {
+	directives := map[string]string{"prefix": "prefix", "desc": "description", "scope": "scope"}
+	for _, tt := range []struct {
+		name       string
+		directives map[string]string
+		content    string
+		want       File
+		warnings   int
+	}{
+		{"disabled", nil, "// @prefix: r\nfor {}\n",
+			File{Prefix: Prefix{"retry"}, Body: Body{"// @prefix: r", "for {}"}}, 0},
+		{"prefix and description", directives, "// @prefix: r\n// @desc: Retry forever\nfor {}\n",
+			File{Prefix: Prefix{"r"}, Description: "Retry forever", Body: Body{"for {}"}}, 0},
+		{"repeated prefix", directives, "// @prefix: r\n// @prefix: [again, back]\nfor {}\n",
+			File{Prefix: Prefix{"r", "again", "back"}, Body: Body{"for {}"}}, 0},
+		{"other comments kept", directives, "#!/bin/sh\n\n# Retries.\n# @scope: shellscript\nretry\n",
+			File{Prefix: Prefix{"retry"}, Scope: "shellscript", Body: Body{"#!/bin/sh", "", "# Retries.", "retry"}}, 0},
+		{"unknown", directives, "// @author: me\n// @desc: Retry\nfor {}\n",
+			File{Prefix: Prefix{"retry"}, Description: "Retry", Body: Body{"// @author: me", "for {}"}}, 1},
+		{"only at the top", directives, "for {}\n// @desc: Retry\n",
+			File{Prefix: Prefix{"retry"}, Body: Body{"for {}", "// @desc: Retry"}}, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+			_, file := newFile(t, Options{Directives: tt.directives, Logger: logger}, "retry.go", tt.content)
+			if !reflect.DeepEqual(*file, tt.want) {
+				t.Errorf("got %+v, want %+v", *file, tt.want)
+			}
+			if len(logger.warnings) != tt.warnings {
+				t.Errorf("got warnings %q, want %d", logger.warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package snippet
+
+import "strings"
+
+// commentSyntax is the comment syntax of a language: line comments start
+// with line, block comments are enclosed in open and close. Either may be
+// missing.
+type commentSyntax struct {
+	line, open, close string
+}
+
+var (
+	cComments    = commentSyntax{line: "//", open: "/*", close: "*/"}
+	hashComments = commentSyntax{line: "#"}
+	xmlComments  = commentSyntax{open: "<!--", close: "-->"}
+)
+
+// docComments maps language identifiers to their comment syntax, for
+// DescFrom "doc".
+var docComments = map[string]commentSyntax{
+	"c":               cComments,
+	"cpp":             cComments,
+	"csharp":          cComments,
+	"css":             {open: "/*", close: "*/"},
+	"dart":            cComments,
+	"elixir":          hashComments,
+	"fsharp":          {line: "//", open: "(*", close: "*)"},
+	"go":              cComments,
+	"haskell":         {line: "--", open: "{-", close: "-}"},
+	"html":            xmlComments,
+	"java":            cComments,
+	"javascript":      cComments,
+	"javascriptreact": cComments,
+	"julia":           hashComments,
+	"kotlin":          cComments,
+	"less":            cComments,
+	"lua":             {line: "--", open: "--[[", close: "]]"},
+	"makefile":        hashComments,
+	"markdown":        xmlComments,
+	"objective-c":     cComments,
+	"perl":            hashComments,
+	"php":             cComments,
+	"powershell":      {line: "#", open: "<#", close: "#>"},
+	"python":          {line: "#", open: `"""`, close: `"""`},
+	"r":               hashComments,
+	"ruby":            hashComments,
+	"rust":            cComments,
+	"scala":           cComments,
+	"scss":            cComments,
+	"shellscript":     hashComments,
+	"sql":             {line: "--", open: "/*", close: "*/"},
+	"svelte":          xmlComments,
+	"swift":           cComments,
+	"toml":            hashComments,
+	"typescript":      cComments,
+	"typescriptreact": cComments,
+	"vue":             xmlComments,
+	"xml":             xmlComments,
+	"yaml":            hashComments,
+}
+
+// docComment returns the text of the comment leading b, in the comment
+// syntax of language lang, and b without it. A shebang line is kept in
+// place and the comment looked for after it. ok is false if b does not
+// start with a comment.
+func docComment(lang string, b []byte) (text string, body []byte, ok bool) {
+	syntax, known := docComments[lang]
+	if !known {
+		return "", b, false
+	}
+	content := string(b)
+	var shebang string
+	if strings.HasPrefix(content, "#!") {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			return "", b, false
+		}
+		shebang, content = content[:i+1], content[i+1:]
+	}
+
+	rest := strings.TrimLeft(content, " \t\r\n")
+	var lines []string
+	switch {
+	case syntax.open != "" && strings.HasPrefix(rest, syntax.open):
+		end := strings.Index(rest[len(syntax.open):], syntax.close)
+		if end < 0 {
+			return "", b, false
+		}
+		inner := rest[len(syntax.open) : len(syntax.open)+end]
+		rest = rest[len(syntax.open)+end+len(syntax.close):]
+		for _, line := range strings.Split(inner, "\n") {
+			line = strings.TrimSpace(line)
+			// Decorations of /** javadoc-style */ comments.
+			line = strings.TrimSpace(strings.TrimLeft(line, "*"))
+			lines = append(lines, line)
+		}
+	case syntax.line != "" && strings.HasPrefix(rest, syntax.line):
+		for strings.HasPrefix(rest, syntax.line) {
+			line := rest
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				line, rest = rest[:i], rest[i+1:]
+			} else {
+				rest = ""
+			}
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, syntax.line)))
+			rest = strings.TrimLeft(rest, " \t")
+		}
+	default:
+		return "", b, false
+	}
+
+	var words []string
+	for _, line := range lines {
+		if line != "" {
+			words = append(words, line)
+		}
+	}
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	return strings.Join(words, " "), []byte(shebang + rest), true
+}
@@ -0,0 +1,37 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocComment(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		pathName string
+		content  string
+		want     string
+		body     Body
+	}{
+		{"go line comments", "retry.go", "// Retry retries f\n// with backoff.\nfor {}\n", "Retry retries f with backoff.", Body{"for {}"}},
+		{"go block comment", "retry.go", "/**\n * Retry retries f.\n */\nfor {}\n", "Retry retries f.", Body{"for {}"}},
+		{"python hash block", "loop.py", "# Loop forever.\n#\n# Until interrupted.\nwhile True:\n    pass\n", "Loop forever. Until interrupted.", Body{"while True:", "    pass"}},
+		{"python docstring", "loop.py", "\"\"\"Loop forever.\"\"\"\nwhile True: pass\n", "Loop forever.", Body{"while True: pass"}},
+		{"html comment", "page.html", "<!-- A page\n     skeleton. -->\n<html></html>\n", "A page skeleton.", Body{"<html></html>"}},
+		{"sql", "query.sql", "-- Count rows.\nSELECT count(*) FROM t;\n", "Count rows.", Body{"SELECT count(*) FROM t;"}},
+		{"shebang", "run.sh", "#!/bin/sh\n# Run it.\nexec \"$@\"\n", "Run it.", Body{"#!/bin/sh", "exec \"$@\""}},
+		{"no comment", "retry.go", "for {}\n// Not leading.\n", "", Body{"for {}", "// Not leading."}},
+		{"unterminated", "retry.go", "/* Retry\nfor {}\n", "", Body{"/* Retry", "for {}"}},
+		{"unknown language", "notes.txt", "// Notes\ntext\n", "", Body{"// Notes", "text"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, Options{DescFrom: "doc"}, tt.pathName, tt.content)
+			if file.Description != tt.want {
+				t.Errorf("got description %q, want %q", file.Description, tt.want)
+			}
+			if !reflect.DeepEqual(file.Body, tt.body) {
+				t.Errorf("got body %q, want %q", file.Body, tt.body)
+			}
+		})
+	}
+}
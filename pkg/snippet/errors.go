@@ -0,0 +1,27 @@
+package snippet
+
+import "errors"
+
+// Sentinel errors wrapped by the errors of adding and writing snippets,
+// for callers to match with errors.Is.
+var (
+	// ErrNoExtension is returned under Options.NoExtError for files
+	// without extension when there is no Options.DefaultLang.
+	ErrNoExtension = errors.New("file has no extension")
+	// ErrCollision is returned when Options.OnCollision is "error" for
+	// snippet names that already exist.
+	ErrCollision = errors.New("snippet already exists")
+	// ErrReadFailed wraps the errors of reading input and snippet files.
+	ErrReadFailed = errors.New("cannot read")
+	// ErrWriteFailed wraps the errors of writing snippet files.
+	ErrWriteFailed = errors.New("cannot write")
+	// ErrReadOnly is wrapped by ErrWriteFailed errors for read-only
+	// snippet files when Options.Force is not set.
+	ErrReadOnly = errors.New("file is read-only")
+	// ErrInvalid is returned under Options.Strict for snippets VS Code
+	// would not load or offer.
+	ErrInvalid = errors.New("invalid snippets")
+	// ErrSchema is returned under Options.ValidateSchema for snippet files
+	// violating SnippetsSchema.
+	ErrSchema = errors.New("snippet schema violation")
+)
@@ -0,0 +1,68 @@
+package snippet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	write := func(opts Options, files map[string]string, existing string) error {
+		s, dir := addFiles(t, opts, files)
+		out := filepath.Join(dir, "out")
+		if existing != "" {
+			writeFiles(t, out, map[string]string{"go.json": existing})
+		}
+		return s.Write(context.Background(), out)
+	}
+	for _, tt := range []struct {
+		name string
+		err  func(t *testing.T) error
+		want error
+	}{
+		{"no extension", func(t *testing.T) error {
+			return New(Options{NoExtError: true}).AddReader("Makefile", strings.NewReader("all:\n"))
+		}, ErrNoExtension},
+		{"collision", func(t *testing.T) error {
+			s := New(Options{OnCollision: "error"})
+			if err := s.AddReader("a/loop.go", strings.NewReader("for {}\n")); err != nil {
+				return err
+			}
+			return s.AddReader("b/loop.go", strings.NewReader("for {}\n"))
+		}, ErrCollision},
+		{"read failed", func(t *testing.T) error {
+			return New(Options{}).AddSnippet(filepath.Join(t.TempDir(), "missing.go"))
+		}, ErrReadFailed},
+		{"write failed", func(t *testing.T) error {
+			s, dir := addFiles(t, Options{}, map[string]string{"loop.go": "for {}\n"})
+			return s.Write(context.Background(), filepath.Join(dir, "loop.go"))
+		}, ErrWriteFailed},
+		{"read-only", func(t *testing.T) error {
+			if os.Geteuid() == 0 {
+				t.Skip("permissions do not apply to root")
+			}
+			s, dir := addFiles(t, Options{}, map[string]string{"loop.go": "for {}\n"})
+			out := filepath.Join(dir, "out")
+			writeFiles(t, out, map[string]string{"go.json": "{}"})
+			if err := os.Chmod(filepath.Join(out, "go.json"), 0444); err != nil {
+				t.Fatal(err)
+			}
+			return s.Write(context.Background(), out)
+		}, ErrReadOnly},
+		{"invalid", func(t *testing.T) error {
+			return write(Options{Strict: true}, map[string]string{"empty.go": "\n"}, "")
+		}, ErrInvalid},
+		{"schema", func(t *testing.T) error {
+			return write(Options{Merge: true, ValidateSchema: true}, map[string]string{"loop.go": "for {}\n"}, `{"old": {"prefix": "old"}}`)
+		}, ErrSchema},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.err(t); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package snippet_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"vscode_snippet_generator/pkg/snippet"
+)
+
+func ExampleSnippets_AddReader() {
+	s := snippet.New(snippet.Options{Indent: map[string]string{"": "  "}})
+	if err := s.AddReader("loops/retry.go", strings.NewReader("for {\n\t$1\n}\n")); err != nil {
+		log.Fatal(err)
+	}
+	files, err := s.Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(string(files["go.json"]))
+	// Output:
+	// {
+	//   "retry": {
+	//     "prefix": "retry",
+	//     "description": "",
+	//     "body": [
+	//       "for {",
+	//       "\t$1",
+	//       "}"
+	//     ]
+	//   }
+	// }
+}
+
+func ExampleOptions_NewFile() {
+	opts := snippet.Options{PrefixTemplate: "tpl-{name}"}
+	name, file, err := opts.NewFile("retry.go", strings.NewReader("for {}\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(name, file.Prefix, file.Body)
+	// Output: retry [tpl-retry] [for {}]
+}
+
+// TestLibrary drives the generation from files on disk to snippet files
+// through the exported API only.
+func TestLibrary(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{
+		"retry.go": "for {}\n",
+		"loop.py":  "while True: pass\n",
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := snippet.New(snippet.Options{})
+	for _, name := range []string{"retry.go", "loop.py"} {
+		if err := s.AddSnippet(filepath.Join(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := s.Langs(), []string{"go", "python"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got languages %q, want %q", got, want)
+	}
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(out, "go.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got snippet.Snippet
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := snippet.Snippet{"retry": {Prefix: snippet.Prefix{"retry"}, Body: snippet.Body{"for {}"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,476 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Prefix holds the trigger words of a snippet. A single prefix is encoded
+// as a string, several as an array.
+type Prefix []string
+
+func (p Prefix) MarshalJSON() ([]byte, error) {
+	switch len(p) {
+	case 0:
+		return json.Marshal("")
+	case 1:
+		return json.Marshal(p[0])
+	}
+	return json.Marshal([]string(p))
+}
+
+func (p *Prefix) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*p = Prefix{text}
+		return nil
+	}
+	var prefixes []string
+	if err := json.Unmarshal(data, &prefixes); err != nil {
+		return err
+	}
+	*p = prefixes
+	return nil
+}
+
+// File is a snippet, as found in VS Code snippet files.
+type File struct {
+	Prefix      Prefix `json:"prefix"`
+	Description string `json:"description"`
+	Scope       string `json:"scope,omitempty"`
+	Body        Body   `json:"body"`
+	// IsFileTemplate offers the snippet when creating a new file.
+	IsFileTemplate bool `json:"isFileTemplate,omitempty"`
+	// Generated marks the snippets written with Options.Prune, telling
+	// them apart from the ones written by hand.
+	Generated bool `json:"x-generated,omitempty"`
+	// Context documents the when-clause context the snippet is intended
+	// for. VS Code ignores it; it is meant for other tools.
+	Context string `json:"x-context,omitempty"`
+
+	// group is the GroupBy "dir" group of the file the snippet was
+	// generated from.
+	group string
+}
+
+// compactFile encodes a File with a single-line body as a string.
+type compactFile File
+
+func (f compactFile) MarshalJSON() ([]byte, error) {
+	type plain File
+	if len(f.Body) != 1 {
+		return json.Marshal(plain(f))
+	}
+	return json.Marshal(struct {
+		plain
+		Body string `json:"body"`
+	}{plain(f), f.Body[0]})
+}
+
+// splitName splits fileName into its base name and extension, without the
+// leading dot. Dotfiles such as .gitignore have no extension.
+func splitName(fileName string) (string, string) {
+	ext := filepath.Ext(fileName)
+	if ext == fileName {
+		return fileName, ""
+	}
+	return fileName[:len(fileName)-len(ext)], strings.TrimPrefix(ext, ".")
+}
+
+// split returns the name and extension of the file at pathName. Dotfiles
+// lose their leading dot and, without extension, are their own extension:
+// .eslintrc.json gives eslintrc and json, .gitignore gives gitignore twice.
+// With Dotfiles "noext" they are split as any other file instead.
+func (o *Options) split(pathName string) (string, string) {
+	name, ext := splitName(filepath.Base(pathName))
+	if o.Dotfiles == "noext" || !strings.HasPrefix(name, ".") || strings.Trim(name, ".") == "" {
+		return name, ext
+	}
+	name = name[1:]
+	if ext == "" {
+		ext = name
+	}
+	return name, ext
+}
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValidateTemplate reports placeholders in tmpl that are not listed in
+// names.
+func ValidateTemplate(tmpl string, names ...string) error {
+	for _, m := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		known := false
+		for _, name := range names {
+			if m[1] == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("template %q: unknown placeholder %s", tmpl, m[0])
+		}
+	}
+	return nil
+}
+
+// renderPrefix renders PrefixTemplate for the file at pathName, followed
+// by its extension under PrefixWithExt.
+func (o *Options) renderPrefix(pathName string) string {
+	tmpl := o.PrefixTemplate
+	if tmpl == "" {
+		tmpl = "{name}"
+	}
+	baseName, ext := o.split(pathName)
+	dir := filepath.Base(filepath.Dir(pathName))
+	prefix := strings.NewReplacer(
+		"{name}", baseName,
+		"{dir}", dir,
+		"{ext}", ext,
+	).Replace(tmpl)
+	if o.PrefixWithExt && ext != "" && !strings.Contains(tmpl, "{ext}") {
+		prefix += "." + ext
+	}
+	return prefix
+}
+
+// renderDescription renders DescTemplate for the file at pathName in fsys,
+// of size bytes. {mtime} is empty if the file cannot be stated, as the
+// sections of SplitOn.
+func (o *Options) renderDescription(fsys fs.FS, pathName string, size int64) string {
+	baseName, ext := o.split(pathName)
+	mtime := ""
+	if info, err := fs.Stat(fsys, pathName); err == nil {
+		layout := o.DescTimeFormat
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		mtime, size = info.ModTime().Format(layout), info.Size()
+	}
+	return strings.NewReplacer(
+		"{name}", baseName,
+		"{path}", o.relPath(pathName),
+		"{ext}", ext,
+		"{mtime}", mtime,
+		"{size}", strconv.FormatInt(size, 10),
+	).Replace(o.DescTemplate)
+}
+
+// Name returns the name of the snippet for the file at pathName.
+func (o *Options) Name(pathName string) string {
+	baseName, ext := o.split(pathName)
+	switch {
+	case o.NameTemplate != "":
+		sep := o.PathSep
+		if sep == "" {
+			sep = "/"
+		}
+		return applyCase(strings.NewReplacer(
+			"{name}", baseName,
+			"{dir}", filepath.Base(filepath.Dir(pathName)),
+			"{ext}", ext,
+			"{relpath}", strings.ReplaceAll(o.namePath(pathName), "/", sep),
+		).Replace(o.NameTemplate), o.NameCase)
+	case o.NameFrom == "path":
+		return applyCase(o.namePath(pathName), o.NameCase)
+	}
+	return applyCase(baseName, o.NameCase)
+}
+
+// namePath returns the path of the file at pathName without extension,
+// relative to StripPrefix if set, with / separators.
+func (o *Options) namePath(pathName string) string {
+	baseName, _ := o.split(pathName)
+	dir := filepath.Dir(pathName)
+	if o.StripPrefix != "" {
+		if rel, ok := relTo(o.StripPrefix, dir); ok {
+			dir = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Join(dir, baseName))
+}
+
+// groupOf returns the GroupBy "dir" group of the file at pathName, added
+// from the directory root: its top-level directory relative to StripPrefix,
+// or root, or the name of its directory for the files at the top level and
+// outside. An empty root is the current directory.
+func (o *Options) groupOf(root, pathName string) string {
+	if root == "" {
+		root = "."
+	}
+	dir := filepath.Dir(pathName)
+	if o.StripPrefix != "" {
+		if rel, ok := relTo(o.StripPrefix, dir); ok {
+			root, dir = o.StripPrefix, rel
+		}
+	} else if rel, ok := relTo(root, dir); ok {
+		dir = rel
+	}
+	switch {
+	case dir == ".":
+		if abs, err := filepath.Abs(root); err == nil {
+			return filepath.Base(abs)
+		}
+		return root
+	case filepath.IsLocal(dir):
+		return strings.SplitN(filepath.ToSlash(dir), "/", 2)[0]
+	}
+	return filepath.Base(dir)
+}
+
+// relTo returns pathName relative to base, if pathName is within base.
+func relTo(base, pathName string) (string, bool) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", false
+	}
+	absPath, err := filepath.Abs(pathName)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// relPath returns pathName relative to RelativeTo, or the current directory,
+// with / separators. Paths outside of it are kept as they are.
+func (o *Options) relPath(pathName string) string {
+	base := o.RelativeTo
+	if base == "" {
+		base = "."
+	}
+	if rel, ok := relTo(base, pathName); ok {
+		pathName = rel
+	}
+	return filepath.ToSlash(pathName)
+}
+
+// DescExt is the extension of description sidecar files.
+const DescExt = ".desc"
+
+var commentMarkers = []string{"//", "#", "--"}
+
+// commentText returns the text of line without its comment marker, if
+// line is a comment.
+func commentText(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	for _, marker := range commentMarkers {
+		if strings.HasPrefix(line, marker) {
+			return strings.TrimSpace(line[len(marker):]), true
+		}
+	}
+	return "", false
+}
+
+// AliasesExt is the extension of alias sidecar files.
+const AliasesExt = ".aliases"
+
+// readAliases returns the additional prefixes of the file at pathName, read
+// from its optional sidecar file and separated by spaces, commas or lines.
+func readAliases(fsys fs.FS, pathName string) ([]string, error) {
+	fileName := pathName + AliasesExt
+	b, err := fs.ReadFile(fsys, fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+	}
+	return strings.FieldsFunc(string(b), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}), nil
+}
+
+// describe returns the description of the file at pathName with content b,
+// according to DescFrom, and the content left for the body. Sidecar files are
+// read from fsys.
+func (o *Options) describe(fsys fs.FS, pathName string, b []byte) (string, []byte, error) {
+	switch o.DescFrom {
+	case "sidecar":
+		fileName := pathName + DescExt
+		d, err := fs.ReadFile(fsys, fileName)
+		if err == nil {
+			return strings.TrimSpace(string(d)), b, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+		}
+		fallthrough
+	case "firstline":
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i]
+		}
+		if text, ok := commentText(string(line)); ok {
+			return text, b, nil
+		}
+	case "doc":
+		_, ext := o.split(pathName)
+		if text, body, ok := docComment(o.LangOf(ext), b); ok {
+			return text, body, nil
+		}
+	}
+	return "", b, nil
+}
+
+// binarySniffLen is how much of a file is searched for NUL bytes.
+const binarySniffLen = 8000
+
+// isBinary reports whether b does not look like text: it is not valid UTF-8
+// or it has NUL bytes at its start.
+func isBinary(b []byte) bool {
+	head := b
+	if len(head) > binarySniffLen {
+		head = head[:binarySniffLen]
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(b)
+}
+
+// NewFile returns the snippet of the file at pathName with the content of
+// r, and its name. The file is nil if it is skipped for being too large,
+// binary or filtered out by RequireMatch and ExcludeMatch. SplitOn is
+// ignored: see Snippet.AddFile.
+func (o *Options) NewFile(pathName string, r io.Reader) (string, *File, error) {
+	b, err := o.readContent(pathName, r)
+	if err != nil || b == nil {
+		return "", nil, err
+	}
+	return o.fileOf(osFS{}, pathName, b)
+}
+
+// readContent returns the content of r, the file at pathName, or nil if the
+// file is skipped for being too large, binary or filtered out by its
+// content.
+func (o *Options) readContent(pathName string, r io.Reader) ([]byte, error) {
+	if o.MaxSize > 0 {
+		r = io.LimitReader(r, o.MaxSize+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+	}
+	if o.MaxSize > 0 && int64(len(b)) > o.MaxSize {
+		o.warnf("skipping %s: larger than %d bytes", pathName, o.MaxSize)
+		o.skip(pathName, fmt.Sprintf("larger than %d bytes", o.MaxSize))
+		return nil, nil
+	}
+	if !o.IncludeBinary && isBinary(b) {
+		o.verbosef("skipping binary %s", pathName)
+		o.skip(pathName, "binary")
+		return nil, nil
+	}
+	if o.RequireMatch != nil && !o.RequireMatch.Match(b) {
+		o.verbosef("skipping %s: no match for %s", pathName, o.RequireMatch)
+		o.skip(pathName, "no content match")
+		return nil, nil
+	}
+	if o.ExcludeMatch != nil && o.ExcludeMatch.Match(b) {
+		o.verbosef("skipping %s: match for %s", pathName, o.ExcludeMatch)
+		o.skip(pathName, "excluded content match")
+		return nil, nil
+	}
+	return b, nil
+}
+
+// fileOf returns the snippet of the file at pathName with content b, and
+// its name.
+func (o *Options) fileOf(fsys fs.FS, pathName string, b []byte) (string, *File, error) {
+	_, ext := o.split(pathName)
+	size := int64(len(b))
+	fm, b := parseFrontmatter(b)
+	dm, b := o.parseDirectives(pathName, b)
+
+	description, b, err := o.describe(fsys, pathName, b)
+	if err != nil {
+		return "", nil, err
+	}
+	if b, err = o.transform(pathName, b); err != nil {
+		return "", nil, err
+	}
+	prefix := Prefix{o.renderPrefix(pathName)}
+	if o.Aliases {
+		aliases, err := readAliases(fsys, pathName)
+		if err != nil {
+			return "", nil, err
+		}
+		prefix = append(prefix, aliases...)
+	}
+	file := &File{
+		Prefix:         prefix,
+		Description:    description,
+		Scope:          o.scopeOf(ext),
+		Body:           o.NewBody(b),
+		IsFileTemplate: o.FileTemplate,
+		Context:        o.Context,
+	}
+	if o.Fence {
+		lang := o.DefaultLang
+		if ext != "" {
+			lang = o.LangOf(ext)
+		}
+		file.Body = fence(lang, file.Body)
+	}
+	if fm != nil {
+		fm.apply(file)
+	}
+	if dm != nil {
+		dm.apply(file)
+	}
+	if o.PrefixNamespace != "" {
+		for i, p := range file.Prefix {
+			file.Prefix[i] = o.PrefixNamespace + p
+		}
+	}
+	if file.Description == "" {
+		switch {
+		case o.DescTemplate != "":
+			file.Description = o.renderDescription(fsys, pathName, size)
+		case o.DescPath:
+			file.Description = o.relPath(pathName)
+		}
+	}
+	file.Description = sanitizeDescription(file.Description, o.DescMaxLen)
+	name := o.Name(pathName)
+	if renamed, ok := o.Rename[name]; ok {
+		name = renamed
+	}
+	return name, file, nil
+}
+
+// fence returns body between ``` Markdown fence lines for language lang.
+func fence(lang string, body Body) Body {
+	fenced := make(Body, 0, len(body)+2)
+	fenced = append(fenced, "```"+lang)
+	fenced = append(fenced, body...)
+	return append(fenced, "```")
+}
+
+// sanitizeDescription returns desc on a single line, with its control
+// characters and runs of whitespace replaced by single spaces, and truncated
+// with an ellipsis to maxLen characters if positive.
+func sanitizeDescription(desc string, maxLen int) string {
+	desc = strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, desc)), " ")
+	if maxLen <= 0 || utf8.RuneCountInString(desc) <= maxLen {
+		return desc
+	}
+	runes := []rune(desc)
+	return strings.TrimRight(string(runes[:maxLen-1]), " ") + "…"
+}
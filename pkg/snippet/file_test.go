@@ -0,0 +1,573 @@
+package snippet
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFile returns the snippet of the file at pathName with content, and its
+// name.
+func newFile(t *testing.T, opts Options, pathName, content string) (string, *File) {
+	t.Helper()
+	name, file, err := opts.NewFile(pathName, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil {
+		t.Fatalf("%s skipped", pathName)
+	}
+	return name, file
+}
+
+func TestPrefixTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want Prefix
+	}{
+		{"default", Options{}, Prefix{"retry"}},
+		{"name", Options{PrefixTemplate: "tpl-{name}"}, Prefix{"tpl-retry"}},
+		{"dir", Options{PrefixTemplate: "{dir}:{name}"}, Prefix{"net:retry"}},
+		{"ext", Options{PrefixTemplate: "{name}.{ext}"}, Prefix{"retry.go"}},
+		{"with ext", Options{PrefixWithExt: true}, Prefix{"retry.go"}},
+		{"template with ext", Options{PrefixWithExt: true, PrefixTemplate: "{dir}:{name}"}, Prefix{"net:retry.go"}},
+		{"template ext wins", Options{PrefixWithExt: true, PrefixTemplate: "{ext}-{name}"}, Prefix{"go-retry"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, tt.opts, "templates/net/retry.go", "for {}\n")
+			if !reflect.DeepEqual(file.Prefix, tt.want) {
+				t.Errorf("got prefix %q, want %q", file.Prefix, tt.want)
+			}
+		})
+	}
+
+	opts := Options{PrefixWithExt: true}
+	if got := opts.renderPrefix(filepath.FromSlash("templates/Makefile")); got != "Makefile" {
+		t.Errorf("got prefix %q without extension", got)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{"{name}", false},
+		{"{dir}:{name}.{ext}", false},
+		{"plain", false},
+		{"{nmae}", true},
+		{"{name}-{}", true},
+	} {
+		err := ValidateTemplate(tt.tmpl, "name", "dir", "ext")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDescFrom(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"retry.go.desc": "Retry with backoff\n",
+	})
+	for _, tt := range []struct {
+		name     string
+		descFrom string
+		file     string
+		content  string
+		want     string
+	}{
+		{"none", "none", "retry.go", "// Retries\nfor {}\n", ""},
+		{"default", "", "retry.go", "// Retries\nfor {}\n", ""},
+		{"sidecar", "sidecar", "retry.go", "// Retries\nfor {}\n", "Retry with backoff"},
+		{"sidecar fallback", "sidecar", "loop.go", "// Loops\nfor {}\n", "Loops"},
+		{"firstline slashes", "firstline", "loop.go", "//   Loops\nfor {}\n", "Loops"},
+		{"firstline hash", "firstline", "loop.sh", "# Loops\nwhile true; do :; done\n", "Loops"},
+		{"firstline dashes", "firstline", "loop.sql", "-- Loops\nSELECT 1;\n", "Loops"},
+		{"firstline not a comment", "firstline", "loop.go", "for {}\n// Loops\n", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, Options{DescFrom: tt.descFrom}, filepath.Join(dir, tt.file), tt.content)
+			if file.Description != tt.want {
+				t.Errorf("got description %q, want %q", file.Description, tt.want)
+			}
+		})
+	}
+}
+
+func TestSidecarNotASnippet(t *testing.T) {
+	opts := Options{DescFrom: "sidecar"}
+	if lang, err := opts.Language("retry.go.desc"); lang != "" || err != nil {
+		t.Errorf("got language %q and error %v for a sidecar file", lang, err)
+	}
+}
+
+func TestScope(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		opts      Options
+		pathName  string
+		wantScope string
+	}{
+		{"unset", Options{}, "loop.js", ""},
+		{"global", Options{Scope: "javascript,typescript"}, "loop.js", "javascript,typescript"},
+		{"mapped", Options{Scope: "javascript", ScopeMap: map[string]string{"ts": "typescript"}}, "loop.ts", "typescript"},
+		{"not mapped", Options{Scope: "javascript", ScopeMap: map[string]string{"ts": "typescript"}}, "loop.js", "javascript"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, tt.opts, tt.pathName, "for (;;) {}\n")
+			if file.Scope != tt.wantScope {
+				t.Errorf("got scope %q, want %q", file.Scope, tt.wantScope)
+			}
+			b, err := json.Marshal(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(b), `"scope"`); got != (tt.wantScope != "") {
+				t.Errorf("got %s, scope field present: %t", b, got)
+			}
+		})
+	}
+}
+
+func TestNameFromPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		want     string
+	}{
+		{"base", Options{}, "templates/go/helpers/retry.go", "retry"},
+		{"path", Options{NameFrom: "path"}, "templates/go/helpers/retry.go", "templates/go/helpers/retry"},
+		{"stripped", Options{NameFrom: "path", StripPrefix: "templates"}, "templates/go/helpers/retry.go", "go/helpers/retry"},
+		{"stripped slash", Options{NameFrom: "path", StripPrefix: "templates/"}, "templates/go/helpers/retry.go", "go/helpers/retry"},
+		{"stripped absolute", Options{NameFrom: "path", StripPrefix: filepath.Join(wd, "templates")}, "templates/go/retry.go", "go/retry"},
+		{"cleaned", Options{NameFrom: "path"}, "./templates//go/../retry.go", "templates/retry"},
+		{"outside prefix", Options{NameFrom: "path", StripPrefix: "templates"}, "other/retry.go", "other/retry"},
+		{"top level", Options{NameFrom: "path", StripPrefix: "templates"}, "templates/retry.go", "retry"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Name(filepath.FromSlash(tt.pathName)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		want     string
+	}{
+		{"dot joined", Options{NameTemplate: "{relpath}", PathSep: ".", StripPrefix: "templates"}, "templates/go/http/client.go", "go.http.client"},
+		{"underscore joined", Options{NameTemplate: "{relpath}", PathSep: "_", StripPrefix: "templates"}, "templates/go/http/client.go", "go_http_client"},
+		{"default separator", Options{NameTemplate: "{relpath}", StripPrefix: "templates"}, "templates/go/http/client.go", "go/http/client"},
+		{"not stripped", Options{NameTemplate: "{relpath}", PathSep: "-"}, "go/http/client.go", "go-http-client"},
+		{"placeholders", Options{NameTemplate: "{dir}-{name}.{ext}"}, "go/http/client.go", "http-client.go"},
+		{"over name from", Options{NameTemplate: "x-{name}", NameFrom: "path"}, "go/http/client.go", "x-client"},
+		{"cased", Options{NameTemplate: "{relpath}", PathSep: ".", NameCase: "upper"}, "go/http/client.go", "GO.HTTP.CLIENT"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Name(filepath.FromSlash(tt.pathName)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The full path keeps files of the same name apart.
+	s, _ := addFiles(t, Options{NameTemplate: "{relpath}", PathSep: "."}, map[string]string{
+		"http/client.go": "http.Get()\n",
+		"grpc/client.go": "grpc.Dial()\n",
+	})
+	if got := s.Lang("go").Keys(); len(got) != 2 || !strings.HasSuffix(got[0], "grpc.client") || !strings.HasSuffix(got[1], "http.client") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRename(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want Snippet
+	}{
+		{"renamed", Options{Rename: map[string]string{"loop": "for-loop"}}, Snippet{
+			"for-loop": {Prefix: Prefix{"loop"}, Body: Body{"for {}"}},
+			"retry":    {Prefix: Prefix{"retry"}, Body: Body{"retry()"}},
+		}},
+		{"after derivation", Options{NameCase: "upper", Rename: map[string]string{"loop": "x", "LOOP": "for-loop"}}, Snippet{
+			"for-loop": {Prefix: Prefix{"loop"}, Body: Body{"for {}"}},
+			"RETRY":    {Prefix: Prefix{"retry"}, Body: Body{"retry()"}},
+		}},
+		{"collision", Options{OnCollision: "rename", Rename: map[string]string{"loop": "retry"}}, Snippet{
+			"retry":   {Prefix: Prefix{"loop"}, Body: Body{"for {}"}},
+			"retry-2": {Prefix: Prefix{"retry"}, Body: Body{"retry()"}},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, tt.opts, map[string]string{"loop.go": "for {}\n", "retry.go": "retry()\n"})
+			if got := *s.Lang("go"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipBinary(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		content       string
+		includeBinary bool
+		skipped       bool
+	}{
+		{"text", "for {}\n", false, false},
+		{"utf-8", "fmt.Println(\"héllo, 世界\")\n", false, false},
+		{"nul", "\x7fELF\x02\x01\x01\x00\x00", false, true},
+		{"invalid utf-8", "\x89PNG\r\n\x1a\n\xff\xfe", false, true},
+		{"nul after the sniffed head", strings.Repeat("a", binarySniffLen) + "\x00", false, false},
+		{"include binary", "\x7fELF\x02\x01\x01\x00\x00", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{IncludeBinary: tt.includeBinary}
+			_, file, err := opts.NewFile("bin/tool.go", strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped := file == nil; skipped != tt.skipped {
+				t.Errorf("got skipped %t, want %t", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestContentMatch(t *testing.T) {
+	marker := regexp.MustCompile(`(?m)^// snippet$`)
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		content string
+		reason  string
+	}{
+		{"no filter", Options{}, "for {}\n", ""},
+		{"required marker", Options{RequireMatch: marker}, "// snippet\nfor {}\n", ""},
+		{"no required marker", Options{RequireMatch: marker}, "for {}\n", "no content match"},
+		{"marker elsewhere", Options{RequireMatch: marker}, "x := 1 // snippet\n", "no content match"},
+		{"excluded marker", Options{ExcludeMatch: marker}, "// snippet\nfor {}\n", "excluded content match"},
+		{"no excluded marker", Options{ExcludeMatch: marker}, "for {}\n", ""},
+		{"both", Options{RequireMatch: regexp.MustCompile("for"), ExcludeMatch: regexp.MustCompile("TODO")}, "for {} // TODO\n", "excluded content match"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var reasons []string
+			tt.opts.OnSkip = func(pathName, reason string) { reasons = append(reasons, reason) }
+			_, file, err := tt.opts.NewFile("loop.go", strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped := file == nil; skipped != (tt.reason != "") {
+				t.Errorf("got skipped %t, want %t", skipped, tt.reason != "")
+			}
+			if want := []string{tt.reason}; tt.reason != "" && !reflect.DeepEqual(reasons, want) {
+				t.Errorf("got reasons %q, want %q", reasons, want)
+			}
+		})
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		size    int
+		maxSize int64
+		skipped bool
+	}{
+		{"under", 1023, 1024, false},
+		{"at", 1024, 1024, false},
+		{"over", 1025, 1024, true},
+		{"unlimited", 1 << 20, 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{MaxSize: tt.maxSize}
+			_, file, err := opts.NewFile("big.txt", strings.NewReader(strings.Repeat("a", tt.size)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped := file == nil; skipped != tt.skipped {
+				t.Errorf("got skipped %t, want %t", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestPrefixJSON(t *testing.T) {
+	for _, tt := range []struct {
+		prefix Prefix
+		want   string
+	}{
+		{nil, `""`},
+		{Prefix{"retry"}, `"retry"`},
+		{Prefix{"retry", "backoff"}, `["retry","backoff"]`},
+	} {
+		b, err := json.Marshal(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.prefix, b, tt.want)
+		}
+		var got Prefix
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(tt.prefix) > 0 && !reflect.DeepEqual(got, tt.prefix) {
+			t.Errorf("%s: got %q, want %q", b, got, tt.prefix)
+		}
+	}
+	var got Prefix
+	if err := json.Unmarshal([]byte(`{"prefix": 1}`), &got); err == nil {
+		t.Error("got no error for an object prefix")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"retry.go":         "for {}\n",
+		"retry.go.aliases": "backoff, again\nloop\n",
+		"wait.go":          "select {}\n",
+	})
+	for _, tt := range []struct {
+		name    string
+		aliases bool
+		file    string
+		want    Prefix
+	}{
+		{"disabled", false, "retry.go", Prefix{"retry"}},
+		{"sidecar", true, "retry.go", Prefix{"retry", "backoff", "again", "loop"}},
+		{"no sidecar", true, "wait.go", Prefix{"wait"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, Options{Aliases: tt.aliases}, filepath.Join(dir, tt.file), "for {}\n")
+			if !reflect.DeepEqual(file.Prefix, tt.want) {
+				t.Errorf("got prefix %q, want %q", file.Prefix, tt.want)
+			}
+		})
+	}
+	opts := Options{Aliases: true}
+	if lang, err := opts.Language("retry.go.aliases"); lang != "" || err != nil {
+		t.Errorf("got language %q and error %v for an alias sidecar file", lang, err)
+	}
+}
+
+func TestDescPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		content  string
+		want     string
+	}{
+		{"relative to", Options{DescPath: true, RelativeTo: "templates"}, "templates/go/net/retry.go", "for {}\n", "go/net/retry.go"},
+		{"relative to absolute", Options{DescPath: true, RelativeTo: filepath.Join(wd, "templates")}, "templates/go/retry.go", "for {}\n", "go/retry.go"},
+		{"current directory", Options{DescPath: true}, "templates/go/retry.go", "for {}\n", "templates/go/retry.go"},
+		{"outside", Options{DescPath: true, RelativeTo: "templates"}, "other/retry.go", "for {}\n", "other/retry.go"},
+		{"disabled", Options{RelativeTo: "templates"}, "templates/go/retry.go", "for {}\n", ""},
+		{"other description", Options{DescPath: true, DescFrom: "firstline"}, "templates/retry.go", "// Retries\nfor {}\n", "Retries"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, tt.opts, filepath.FromSlash(tt.pathName), tt.content)
+			if file.Description != tt.want {
+				t.Errorf("got description %q, want %q", file.Description, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"http/client.go": "http.Get()\n"})
+	pathName := filepath.Join(dir, "http", "client.go")
+	mtime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(pathName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		content  string
+		want     string
+	}{
+		{"name and mtime", Options{DescTemplate: "from {name}.{ext}, modified {mtime}", DescTimeFormat: "2006-01-02"}, pathName, "", "from client.go, modified 2024-01-02"},
+		{"default time format", Options{DescTemplate: "{mtime}"}, pathName, "", mtime.Local().Format(time.RFC3339)},
+		{"path and size", Options{DescTemplate: "{path} ({size} bytes)", RelativeTo: dir}, pathName, "", "http/client.go (11 bytes)"},
+		{"missing file", Options{DescTemplate: "{name} [{mtime}] {size}"}, "gone/retry.go", "retry()\n", "retry [] 8"},
+		{"other description", Options{DescTemplate: "{name}", DescFrom: "firstline"}, "retry.go", "// Retries\nretry()\n", "Retries"},
+		{"no template", Options{}, pathName, "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.content
+			if content == "" {
+				b, err := os.ReadFile(tt.pathName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				content = string(b)
+			}
+			_, file := newFile(t, tt.opts, tt.pathName, content)
+			if file.Description != tt.want {
+				t.Errorf("got description %q, want %q", file.Description, tt.want)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"unset", Options{}, `{"loop":{"prefix":"loop","description":"","body":["for {}"]}}`},
+		{"set", Options{Context: "editorLangId == go"}, `{"loop":{"prefix":"loop","description":"","body":["for {}"],"x-context":"editorLangId == go"}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name, file := newFile(t, tt.opts, "loop.go", "for {}\n")
+			if file.Context != tt.opts.Context {
+				t.Errorf("got context %q, want %q", file.Context, tt.opts.Context)
+			}
+			b, err := json.Marshal(Snippet{name: file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixNamespace(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"retry.go.aliases": "backoff\n"})
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want Prefix
+	}{
+		{"none", Options{}, Prefix{"retry"}},
+		{"namespace", Options{PrefixNamespace: "mylib."}, Prefix{"mylib.retry"}},
+		{"template", Options{PrefixNamespace: "mylib.", PrefixTemplate: "go-{name}"}, Prefix{"mylib.go-retry"}},
+		{"aliases", Options{PrefixNamespace: "mylib.", Aliases: true}, Prefix{"mylib.retry", "mylib.backoff"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name, file := newFile(t, tt.opts, filepath.Join(dir, "retry.go"), "for {}\n")
+			if name != "retry" {
+				t.Errorf("got name %q, want %q", name, "retry")
+			}
+			if !reflect.DeepEqual(file.Prefix, tt.want) {
+				t.Errorf("got prefix %q, want %q", file.Prefix, tt.want)
+			}
+		})
+	}
+
+	_, file := newFile(t, Options{PrefixNamespace: "mylib."}, "retry.go", "---\nprefix: [r, retry]\n---\nfor {}\n")
+	if want := (Prefix{"mylib.r", "mylib.retry"}); !reflect.DeepEqual(file.Prefix, want) {
+		t.Errorf("got frontmatter prefix %q, want %q", file.Prefix, want)
+	}
+}
+
+func TestFileTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		content string
+		want    bool
+	}{
+		{"default", Options{}, "package main\n", false},
+		{"enabled", Options{FileTemplate: true}, "package main\n", true},
+		{"frontmatter", Options{}, "---\nisFileTemplate: true\n---\npackage main\n", true},
+		{"frontmatter override", Options{FileTemplate: true}, "---\nisFileTemplate: false\n---\npackage main\n", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, tt.opts, "main.go", tt.content)
+			if file.IsFileTemplate != tt.want {
+				t.Errorf("got isFileTemplate %t, want %t", file.IsFileTemplate, tt.want)
+			}
+			b, err := json.Marshal(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(b), `"isFileTemplate":true`); got != tt.want {
+				t.Errorf("got %s, want isFileTemplate only when enabled", b)
+			}
+			if !tt.want && strings.Contains(string(b), "isFileTemplate") {
+				t.Errorf("got %s, want isFileTemplate omitted", b)
+			}
+		})
+	}
+}
+
+func TestSanitizeDescription(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		maxLen int
+		want   string
+	}{
+		{"Retry forever", 0, "Retry forever"},
+		{"Retry\nforever,\r\n\tthen\x00 give up\x7f", 0, "Retry forever, then give up"},
+		{"  padded  ", 0, "padded"},
+		{"Retry forever", 13, "Retry forever"},
+		{"Retry forever", 12, "Retry forev…"},
+		{"Retry forever", 7, "Retry…"},
+		{"héllo wörld", 5, "héll…"},
+		{"Retry\n\n  with a very long\ndescription", 16, "Retry with a ve…"},
+	} {
+		if got := sanitizeDescription(tt.desc, tt.maxLen); got != tt.want {
+			t.Errorf("%q, %d: got %q, want %q", tt.desc, tt.maxLen, got, tt.want)
+		}
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"retry.go.desc": "Retry the call\nuntil it succeeds, waiting\nlonger every time.\n"})
+	_, file := newFile(t, Options{DescFrom: "sidecar", DescMaxLen: 30}, filepath.Join(dir, "retry.go"), "for {}\n")
+	if want := "Retry the call until it succe…"; file.Description != want {
+		t.Errorf("got description %q, want %q", file.Description, want)
+	}
+}
+
+func TestFence(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		content  string
+		want     Body
+	}{
+		{"disabled", Options{}, "loop.go", "for {\n}\n", Body{"for {", "}"}},
+		{"go", Options{Fence: true}, "loop.go", "for {\n}\n", Body{"```go", "for {", "}", "```"}},
+		{"mapped", Options{Fence: true, LangMap: map[string]string{"tmpl": "html"}}, "page.tmpl", "<p></p>\n", Body{"```html", "<p></p>", "```"}},
+		{"default language", Options{Fence: true, DefaultLang: "shellscript"}, "Makefile", "all:\n", Body{"```shellscript", "all:", "```"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, tt.opts, tt.pathName, tt.content)
+			if !reflect.DeepEqual(file.Body, tt.want) {
+				t.Errorf("got body %q, want %q", file.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package snippet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// fileExt returns the extension of snippet files: ext, the extension of JSON
+// snippet files, or the one of OutputFormat.
+func (o *Options) fileExt(ext string) string {
+	switch o.OutputFormat {
+	case "yaml":
+		return ".yaml"
+	case "toml":
+		return ".toml"
+	}
+	return ext
+}
+
+// commentMarker returns the line comment marker of OutputFormat.
+func (o *Options) commentMarker() string {
+	if o.OutputFormat == "yaml" || o.OutputFormat == "toml" {
+		return "#"
+	}
+	return "//"
+}
+
+// orderedKeys returns keys or, if nil, the keys of snippet in the order of
+// SortBy.
+func (o *Options) orderedKeys(snippet *Snippet, keys []string) []string {
+	if keys != nil {
+		return keys
+	}
+	if o.SortBy == "prefix" {
+		return snippet.keysByPrefix()
+	}
+	return snippet.Keys()
+}
+
+// quote returns s as a double-quoted string, which has the same escapes in
+// JSON, YAML and TOML.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func quoteAll(ss []string) []string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = quote(s)
+	}
+	return quoted
+}
+
+// encodeYAML writes snippet to w as YAML, in the order of keys and indented
+// with indent. Bodies are always sequences of lines.
+func (o *Options) encodeYAML(w io.Writer, snippet *Snippet, keys []string, indent string) error {
+	// YAML forbids tabs in indentation.
+	if strings.Trim(indent, " ") != "" || indent == "" {
+		indent = "  "
+	}
+	bw := bufio.NewWriter(w)
+	for _, k := range o.orderedKeys(snippet, keys) {
+		file := (*snippet)[k]
+		fmt.Fprintf(bw, "%s:\n", quote(k))
+		if len(file.Prefix) == 1 {
+			fmt.Fprintf(bw, "%sprefix: %s\n", indent, quote(file.Prefix[0]))
+		} else {
+			fmt.Fprintf(bw, "%sprefix: [%s]\n", indent, strings.Join(quoteAll(file.Prefix), ", "))
+		}
+		fmt.Fprintf(bw, "%sdescription: %s\n", indent, quote(file.Description))
+		if file.Scope != "" {
+			fmt.Fprintf(bw, "%sscope: %s\n", indent, quote(file.Scope))
+		}
+		if len(file.Body) == 0 {
+			fmt.Fprintf(bw, "%sbody: []\n", indent)
+		} else {
+			fmt.Fprintf(bw, "%sbody:\n", indent)
+			for _, line := range file.Body {
+				fmt.Fprintf(bw, "%s%s- %s\n", indent, indent, quote(line))
+			}
+		}
+		if file.IsFileTemplate {
+			fmt.Fprintf(bw, "%sisFileTemplate: true\n", indent)
+		}
+		if file.Generated {
+			fmt.Fprintf(bw, "%sx-generated: true\n", indent)
+		}
+		if file.Context != "" {
+			fmt.Fprintf(bw, "%sx-context: %s\n", indent, quote(file.Context))
+		}
+	}
+	return bw.Flush()
+}
+
+// encodeTOML writes snippet to w as TOML, a table per snippet in the order
+// of keys, indenting body lines with indent. Bodies are always arrays of
+// lines.
+func (o *Options) encodeTOML(w io.Writer, snippet *Snippet, keys []string, indent string) error {
+	bw := bufio.NewWriter(w)
+	for i, k := range o.orderedKeys(snippet, keys) {
+		file := (*snippet)[k]
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "[%s]\n", quote(k))
+		if len(file.Prefix) == 1 {
+			fmt.Fprintf(bw, "prefix = %s\n", quote(file.Prefix[0]))
+		} else {
+			fmt.Fprintf(bw, "prefix = [%s]\n", strings.Join(quoteAll(file.Prefix), ", "))
+		}
+		fmt.Fprintf(bw, "description = %s\n", quote(file.Description))
+		if file.Scope != "" {
+			fmt.Fprintf(bw, "scope = %s\n", quote(file.Scope))
+		}
+		fmt.Fprintln(bw, "body = [")
+		for _, line := range file.Body {
+			fmt.Fprintf(bw, "%s%s,\n", indent, quote(line))
+		}
+		fmt.Fprintln(bw, "]")
+		if file.IsFileTemplate {
+			fmt.Fprintln(bw, "isFileTemplate = true")
+		}
+		if file.Generated {
+			fmt.Fprintln(bw, "x-generated = true")
+		}
+		if file.Context != "" {
+			fmt.Fprintf(bw, "x-context = %s\n", quote(file.Context))
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,189 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripSnippet exercises the escapes and optional fields of the
+// encodings.
+var roundTripSnippet = Snippet{
+	"loop": {Prefix: Prefix{"loop"}, Description: "Loop forever", Body: Body{"for {", "\t$0", "}"}},
+	"quotes": {
+		Prefix:      Prefix{"q", "quote"},
+		Description: `say "hi" \ <b> # not a comment`,
+		Scope:       "go,templ",
+		Body:        Body{`fmt.Println("héllo, 世界")`, "", `a: b = [c]`},
+	},
+	"file":  {Prefix: Prefix{"file"}, Body: Body{"package main"}, IsFileTemplate: true, Generated: true, Context: "editorLangId == go"},
+	"empty": {Prefix: Prefix{"empty"}, Body: Body{}},
+}
+
+// decodeValue decodes the JSON-compatible scalar or flow list value into v,
+// failing the test otherwise.
+func decodeValue(t *testing.T, value string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		t.Fatalf("decoding %s: %v", value, err)
+	}
+}
+
+// setField sets the field key of file to value, as the YAML and TOML
+// encodings write them.
+func setField(t *testing.T, file *File, key, value string) {
+	t.Helper()
+	switch key {
+	case "prefix":
+		if strings.HasPrefix(value, "[") {
+			decodeValue(t, value, (*[]string)(&file.Prefix))
+		} else {
+			var prefix string
+			decodeValue(t, value, &prefix)
+			file.Prefix = Prefix{prefix}
+		}
+	case "description":
+		decodeValue(t, value, &file.Description)
+	case "scope":
+		decodeValue(t, value, &file.Scope)
+	case "isFileTemplate":
+		decodeValue(t, value, &file.IsFileTemplate)
+	case "x-generated":
+		decodeValue(t, value, &file.Generated)
+	case "x-context":
+		decodeValue(t, value, &file.Context)
+	default:
+		t.Fatalf("unexpected field %s = %s", key, value)
+	}
+}
+
+// decodeYAML decodes the subset of YAML encodeYAML writes.
+func decodeYAML(t *testing.T, b []byte) Snippet {
+	t.Helper()
+	snippet := Snippet{}
+	var file *File
+	for _, line := range strings.Split(string(b), "\n") {
+		text := strings.TrimSpace(line)
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+		case !strings.HasPrefix(line, " "):
+			var key string
+			decodeValue(t, strings.TrimSuffix(text, ":"), &key)
+			file = &File{}
+			snippet[key] = file
+		case strings.HasPrefix(text, "- "):
+			var body string
+			decodeValue(t, strings.TrimPrefix(text, "- "), &body)
+			file.Body = append(file.Body, body)
+		default:
+			key, value, _ := strings.Cut(text, ":")
+			value = strings.TrimSpace(value)
+			if key == "body" {
+				file.Body = Body{}
+				continue
+			}
+			setField(t, file, key, value)
+		}
+	}
+	return snippet
+}
+
+// decodeTOML decodes the subset of TOML encodeTOML writes.
+func decodeTOML(t *testing.T, b []byte) Snippet {
+	t.Helper()
+	snippet := Snippet{}
+	var file *File
+	inBody := false
+	for _, line := range strings.Split(string(b), "\n") {
+		text := strings.TrimSpace(line)
+		switch {
+		case inBody && text == "]":
+			inBody = false
+		case inBody:
+			var body string
+			decodeValue(t, strings.TrimSuffix(text, ","), &body)
+			file.Body = append(file.Body, body)
+		case text == "" || strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "["):
+			var key string
+			decodeValue(t, text[1:len(text)-1], &key)
+			file = &File{}
+			snippet[key] = file
+		case text == "body = [":
+			file.Body, inBody = Body{}, true
+		default:
+			key, value, _ := strings.Cut(text, "=")
+			setField(t, file, strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return snippet
+}
+
+func TestOutputFormats(t *testing.T) {
+	var jsonBuf bytes.Buffer
+	if err := (&Options{}).Encode(&jsonBuf, "go.json", &roundTripSnippet); err != nil {
+		t.Fatal(err)
+	}
+	want := Snippet{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, roundTripSnippet) {
+		t.Fatalf("JSON: got %+v, want %+v", want, roundTripSnippet)
+	}
+
+	for _, tt := range []struct {
+		format string
+		decode func(*testing.T, []byte) Snippet
+	}{
+		{"yaml", decodeYAML},
+		{"toml", decodeTOML},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			opts := Options{OutputFormat: tt.format, Header: "Generated."}
+			var buf bytes.Buffer
+			if err := opts.Encode(&buf, "go"+opts.fileExt(".json"), &roundTripSnippet); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), "# Generated.\n") {
+				t.Errorf("got %q, want a # header", buf.String())
+			}
+			got := tt.decode(t, buf.Bytes())
+			if len(got) != len(want) {
+				t.Fatalf("got %d snippets, want %d:\n%s", len(got), len(want), buf.String())
+			}
+			for k, file := range want {
+				if !reflect.DeepEqual(got[k], file) {
+					t.Errorf("%s: got %+v, want %+v as in JSON", k, got[k], file)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputFormatFiles(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		want   []string
+	}{
+		{"", []string{"go.json"}},
+		{"json", []string{"go.json"}},
+		{"yaml", []string{"go.yaml"}},
+		{"toml", []string{"go.toml"}},
+	} {
+		s, _ := addFiles(t, Options{OutputFormat: tt.format}, map[string]string{"loop.go": "for {}\n"})
+		files, err := s.Files()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for name := range files {
+			got = append(got, name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got files %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package snippet
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// frontmatter holds the snippet metadata set in the header of a source file.
+// Nil fields are not set.
+type frontmatter struct {
+	Prefix      Prefix
+	Description *string
+	Scope       *string
+	// IsFileTemplate is set by isFileTemplate, true or false.
+	IsFileTemplate *bool
+}
+
+const frontmatterDelim = "---"
+
+// parseFrontmatter splits b into its frontmatter and the remaining content.
+// A frontmatter starts with a "---" line and ends with the next one; in
+// between, every line must be blank, a # comment or a "key: value" (YAML)
+// or "key = value" (TOML) line setting prefix, description, scope or
+// isFileTemplate, at least one of them being set.
+// Otherwise b has no frontmatter and is returned unchanged with a nil
+// frontmatter.
+func parseFrontmatter(b []byte) (*frontmatter, []byte) {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if len(lines) == 0 || trimLine(lines[0]) != frontmatterDelim {
+		return nil, b
+	}
+
+	fm := &frontmatter{}
+	keys := 0
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		offset += len(line)
+		text := trimLine(line)
+		if text == frontmatterDelim {
+			// An empty block, such as a horizontal rule, is content.
+			if keys == 0 {
+				return nil, b
+			}
+			return fm, b[offset:]
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if !fm.set(text) {
+			return nil, b
+		}
+		keys++
+	}
+	return nil, b
+}
+
+func trimLine(line []byte) string {
+	return strings.TrimSpace(string(line))
+}
+
+// set sets the field in the "key: value" or "key = value" line, reporting
+// whether it is a known one.
+func (fm *frontmatter) set(line string) bool {
+	i := strings.IndexAny(line, ":=")
+	if i < 0 {
+		return false
+	}
+	key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+	switch key {
+	case "prefix":
+		fm.Prefix = parseList(value)
+	case "description":
+		text := unquote(value)
+		fm.Description = &text
+	case "scope":
+		text := unquote(value)
+		fm.Scope = &text
+	case "isFileTemplate":
+		set, err := strconv.ParseBool(unquote(value))
+		if err != nil {
+			return false
+		}
+		fm.IsFileTemplate = &set
+	default:
+		return false
+	}
+	return true
+}
+
+// parseList parses a [a, b] list, or a single value.
+func parseList(value string) []string {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return []string{unquote(value)}
+	}
+	var list []string
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, unquote(item))
+		}
+	}
+	return list
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if text, err := strconv.Unquote(value); err == nil {
+				return text
+			}
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// apply sets the fields of file set in fm.
+func (fm *frontmatter) apply(file *File) {
+	if fm.Prefix != nil {
+		file.Prefix = fm.Prefix
+	}
+	if fm.Description != nil {
+		file.Description = *fm.Description
+	}
+	if fm.Scope != nil {
+		file.Scope = *fm.Scope
+	}
+	if fm.IsFileTemplate != nil {
+		file.IsFileTemplate = *fm.IsFileTemplate
+	}
+}
@@ -0,0 +1,60 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    *frontmatter
+		rest    string
+	}{
+		{"none", "for {}\n", nil, "for {}\n"},
+		{"yaml", "---\nprefix: [retry, again]\ndescription: \"Retry: forever\"\nscope: go\n---\nfor {}\n",
+			&frontmatter{Prefix: Prefix{"retry", "again"}, Description: ptr("Retry: forever"), Scope: ptr("go")}, "for {}\n"},
+		{"toml", "---\n# metadata\nprefix = 'retry'\n\nisFileTemplate = true\n---\nfor {}\n",
+			&frontmatter{Prefix: Prefix{"retry"}, IsFileTemplate: ptr(true)}, "for {}\n"},
+		{"crlf", "---\r\nscope: go\r\n---\r\nfor {}\r\n", &frontmatter{Scope: ptr("go")}, "for {}\r\n"},
+		{"empty block", "---\n---\nfor {}\n", nil, "---\n---\nfor {}\n"},
+		{"unknown key", "---\ntitle: retry\n---\nfor {}\n", nil, "---\ntitle: retry\n---\nfor {}\n"},
+		{"not a key", "---\nfor {}\n---\n", nil, "---\nfor {}\n---\n"},
+		{"invalid isFileTemplate", "---\nisFileTemplate: maybe\n---\n", nil, "---\nisFileTemplate: maybe\n---\n"},
+		{"unterminated", "---\nscope: go\nfor {}\n", nil, "---\nscope: go\nfor {}\n"},
+		{"not at the start", "\n---\nscope: go\n---\n", nil, "\n---\nscope: go\n---\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest := parseFrontmatter([]byte(tt.content))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if string(rest) != tt.rest {
+				t.Errorf("got content %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestFrontmatter(t *testing.T) {
+	_, file := newFile(t, Options{}, "retry.go", "---\nprefix: [retry, again]\ndescription: Retry forever\nscope: go\n---\nfor {}\n")
+	want := &File{
+		Prefix:      Prefix{"retry", "again"},
+		Description: "Retry forever",
+		Scope:       "go",
+		Body:        Body{"for {}"},
+	}
+	if !reflect.DeepEqual(file, want) {
+		t.Errorf("got %+v, want %+v", file, want)
+	}
+
+	_, file = newFile(t, Options{}, "retry.go", "for {}\n")
+	if !reflect.DeepEqual(file.Prefix, Prefix{"retry"}) || file.Scope != "" {
+		t.Errorf("got %+v without frontmatter", file)
+	}
+}
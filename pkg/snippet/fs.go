@@ -0,0 +1,42 @@
+package snippet
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// osFS is the fs.FS of the operating system, opening names as given so that
+// absolute and relative paths keep working as with os.Open.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// AddFS adds the files under root in fsys, such as an embed.FS or an
+// os.DirFS, walking directories in lexical order. Snippets are named after
+// their path in fsys, where their sidecar files are read from too.
+func (s *Snippets) AddFS(fsys fs.FS, root string) error {
+	err := fs.WalkDir(fsys, root, func(pathName string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if lang, err := s.Options.Language(pathName); lang == "" {
+			return err
+		}
+		f, err := fsys.Open(pathName)
+		if err != nil {
+			return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+		}
+		defer f.Close()
+		return s.addReader(fsys, root, pathName, f)
+	})
+	if err != nil {
+		return fmt.Errorf("walking %s: %w", root, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package snippet
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/go/retry.go":      {Data: []byte("for {}\n")},
+		"templates/go/retry.go.desc": {Data: []byte("Retry forever\n")},
+		"templates/python/loop.py":   {Data: []byte("while True:\n    pass\n")},
+		"templates/README":           {Data: []byte("not a snippet\n")},
+		"other/skipped.go":           {Data: []byte("package other\n")},
+	}
+	for _, tt := range []struct {
+		name string
+		opts Options
+		root string
+		want map[string]Snippet
+	}{
+		{"root", Options{DescFrom: "sidecar"}, "templates", map[string]Snippet{
+			"go":     {"retry": {Prefix: Prefix{"retry"}, Description: "Retry forever", Body: Body{"for {}"}}},
+			"python": {"loop": {Prefix: Prefix{"loop"}, Body: Body{"while True:", "    pass"}}},
+		}},
+		{"path names", Options{NameFrom: "path", StripPrefix: "templates"}, "templates/python", map[string]Snippet{
+			"python": {"python/loop": {Prefix: Prefix{"loop"}, Body: Body{"while True:", "    pass"}}},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.opts)
+			if err := s.AddFS(fsys, tt.root); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]Snippet{}
+			for _, lang := range s.Langs() {
+				got[lang] = *s.Lang(lang)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	err := New(Options{}).AddFS(fsys, "missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, fs.ErrNotExist)
+	}
+}
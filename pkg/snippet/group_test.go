@@ -0,0 +1,90 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupOf(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name           string
+		opts           Options
+		root, pathName string
+		want           string
+	}{
+		{"top-level directory", Options{}, "templates", "templates/http/client.go", "http"},
+		{"nested", Options{}, "templates", "templates/http/sub/client.go", "http"},
+		{"top level", Options{}, "templates", "templates/client.go", "templates"},
+		{"current directory", Options{}, "", "client.go", filepath.Base(wd)},
+		{"outside", Options{}, "templates", filepath.Join(wd, "..", "elsewhere", "x", "client.go"), "x"},
+		{"stripped", Options{StripPrefix: "templates"}, "templates/http", "templates/http/sub/client.go", "http"},
+		{"stripped top level", Options{StripPrefix: "templates"}, "templates/http", "templates/client.go", "templates"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.groupOf(filepath.FromSlash(tt.root), filepath.FromSlash(tt.pathName)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"http/client.go":     "http.Get()\n",
+		"http/client.py":     "requests.get()\n",
+		"http/sub/server.go": "http.ListenAndServe()\n",
+		"db/query.sql":       "SELECT 1;\n",
+		"db/query.go":        "db.Query()\n",
+	})
+	s := New(Options{GroupBy: "dir", OnCollision: "rename"})
+	for _, name := range []string{"http/client.go", "http/client.py", "http/sub/server.go", "db/query.sql", "db/query.go"} {
+		pathName := filepath.Join(dir, filepath.FromSlash(name))
+		f, err := os.Open(pathName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.AddReaderIn(dir, pathName, f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	outputs, err := s.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mixed languages share a file, every entry scoped.
+	want := map[string]Snippet{
+		"http.code-snippets": {
+			"client":   {Prefix: Prefix{"client"}, Scope: "go", Body: Body{"http.Get()"}},
+			"client-2": {Prefix: Prefix{"client"}, Scope: "python", Body: Body{"requests.get()"}},
+			"server":   {Prefix: Prefix{"server"}, Scope: "go", Body: Body{"http.ListenAndServe()"}},
+		},
+		"db.code-snippets": {
+			"query":   {Prefix: Prefix{"query"}, Scope: "go", Body: Body{"db.Query()"}},
+			"query-2": {Prefix: Prefix{"query"}, Scope: "sql", Body: Body{"SELECT 1;"}},
+		},
+	}
+	got := map[string]Snippet{}
+	for name, snippet := range outputs {
+		got[name] = *snippet
+		for _, file := range *snippet {
+			file.group = ""
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	opts := Options{GroupBy: "dir", OutputFormat: "yaml"}
+	if got := opts.groupName(&File{group: "http"}); got != "http.yaml" {
+		t.Errorf("got %q", got)
+	}
+}
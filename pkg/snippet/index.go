@@ -0,0 +1,53 @@
+package snippet
+
+import "path"
+
+// IndexEntry describes a snippet of a Snippets.
+type IndexEntry struct {
+	Key         string `json:"key"`
+	Prefix      Prefix `json:"prefix"`
+	Language    string `json:"language"`
+	File        string `json:"file"`
+	Description string `json:"description"`
+}
+
+// Index returns an entry per snippet, sorted by language and then by key.
+// File is the name of the snippet file Write writes it into.
+func (s *Snippets) Index() []IndexEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var index []IndexEntry
+	for _, lang := range s.langNames() {
+		snippet := s.lang(lang)
+		for _, k := range snippet.Keys() {
+			file := (*snippet)[k]
+			index = append(index, IndexEntry{
+				Key:         k,
+				Prefix:      file.Prefix,
+				Language:    lang,
+				File:        s.Options.outputOf(lang, k, file),
+				Description: file.Description,
+			})
+		}
+	}
+	return index
+}
+
+// outputOf returns the name of the snippet file holding the snippet key of
+// language lang, file, as Outputs names them.
+func (o *Options) outputOf(lang, key string, file *File) string {
+	if o.Single != "" {
+		return o.singleName()
+	}
+	ext := o.fileExt(".json")
+	if o.Format == "workspace" {
+		ext = o.fileExt(CodeSnippetsExt)
+	}
+	if o.Split {
+		return path.Join(lang, key+ext)
+	}
+	if o.GroupBy == "dir" {
+		return o.groupName(file)
+	}
+	return o.outName(lang, ext)
+}
@@ -0,0 +1,57 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	files := map[string]string{
+		"go/loop.go":  "// Loops.\nfor {}\n",
+		"go/retry.go": "retry()\n",
+		"py/loop.py":  "while True: pass\n",
+	}
+	s, _ := addFiles(t, Options{DescFrom: "firstline", PrefixTemplate: "x-{name}"}, files)
+	want := []IndexEntry{
+		{Key: "loop", Prefix: Prefix{"x-loop"}, Language: "go", File: "go.json", Description: "Loops."},
+		{Key: "retry", Prefix: Prefix{"x-retry"}, Language: "go", File: "go.json"},
+		{Key: "loop", Prefix: Prefix{"x-loop"}, Language: "python", File: "python.json"},
+	}
+	if got := s.Index(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := New(Options{}).Index(); got != nil {
+		t.Errorf("got %+v without snippets", got)
+	}
+
+	// The files of the entries are the ones Outputs holds them in.
+	for _, opts := range []Options{
+		{},
+		{Format: "workspace"},
+		{OutNames: map[string]string{"py": "go"}, OnCollision: "rename"},
+		{Split: true},
+		{Single: "all"},
+		{GroupBy: "dir"},
+		{OutputFormat: "yaml"},
+	} {
+		s, _ := addFiles(t, opts, files)
+		outputs, err := s.Outputs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range s.Index() {
+			snippet, ok := outputs[e.File]
+			if !ok {
+				t.Errorf("%+v: %s is not an output but %q", opts, e.File, outputs.Names())
+				continue
+			}
+			found := false
+			for _, file := range *snippet {
+				found = found || reflect.DeepEqual(file.Prefix, e.Prefix) && file.Description == e.Description
+			}
+			if !found {
+				t.Errorf("%+v: %s %s not in %s", opts, e.Language, e.Key, e.File)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// stripJSONC returns the JSON in b, JSON with comments as VS Code reads it:
+// // and /* */ comments are removed, as are the commas trailing the last
+// element of objects and arrays. Strings are left untouched.
+func stripJSONC(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	// comma is the index in out of a comma that is dropped if only
+	// whitespace separates it from a closing bracket.
+	comma := -1
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(b) && b[j] != '"'; j++ {
+				if b[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(b) {
+				j = len(b) - 1
+			}
+			out = append(out, b[i:j+1]...)
+			i, comma = j, -1
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := bytes.Index(b[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(b)
+				break
+			}
+			i += end + 3
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			out = append(out, c)
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out = append(out[:comma], out[comma+1:]...)
+			}
+			out, comma = append(out, c), -1
+		case c == ',':
+			out, comma = append(out, c), len(out)
+		default:
+			out, comma = append(out, c), -1
+		}
+	}
+	return out
+}
+
+// lastToken returns the index of the last byte of b outside comments and
+// whitespace, or -1 if there is none.
+func lastToken(b []byte) int {
+	last := -1
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+			last = i
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := bytes.Index(b[i+2:], []byte("*/"))
+			if end < 0 {
+				return last
+			}
+			i += end + 3
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			last = i
+		}
+	}
+	if last >= len(b) {
+		last = len(b) - 1
+	}
+	return last
+}
+
+// leadingComment returns the text of the // comment lines at the top of b,
+// separated by newlines, as Header would write them.
+func leadingComment(b []byte) string {
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" && len(lines) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		text := strings.TrimPrefix(line, "//")
+		lines = append(lines, strings.TrimPrefix(text, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// objectKeys returns the keys of the JSON object b, in order.
+func objectKeys(b []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		keys = append(keys, key)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
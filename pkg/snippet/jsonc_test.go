@@ -0,0 +1,88 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		jsonc string
+		want  string
+	}{
+		{"plain", `{"a": [1, 2]}`, `{"a": [1, 2]}`},
+		{"line comment", "// generated\n{\"a\": 1} // trailing\n", "\n{\"a\": 1} \n"},
+		{"block comment", `{/* a */"a": 1}`, `{"a": 1}`},
+		{"trailing commas", "{\"a\": [1, 2,\n],\n}", "{\"a\": [1, 2\n]\n}"},
+		{"comments in strings", `{"a": "// not /* a comment */,}"}`, `{"a": "// not /* a comment */,}"}`},
+		{"escaped quote", `{"a": "\"//\""}`, `{"a": "\"//\""}`},
+		{"unterminated block comment", `{"a": 1} /* a`, `{"a": 1} `},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripJSONC([]byte(tt.jsonc))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeadingComment(t *testing.T) {
+	for _, tt := range []struct {
+		content string
+		want    string
+	}{
+		{"{}", ""},
+		{"// generated\n//\n//  by hand\n{}\n// not leading\n", "generated\n\n by hand"},
+		{"\n\n// generated\n{}", "generated"},
+	} {
+		if got := leadingComment([]byte(tt.content)); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	opts := Options{Header: "Generated by snippetgen.\nDO NOT EDIT."}
+	snippet := &Snippet{"loop": {Prefix: Prefix{"loop"}, Body: Body{"for {}"}}}
+	var buf bytes.Buffer
+	if err := opts.Encode(&buf, "go.json", snippet); err != nil {
+		t.Fatal(err)
+	}
+	want := "// Generated by snippetgen.\n// DO NOT EDIT.\n{"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("got %q, want it to start with %q", buf.String(), want)
+	}
+	var got Snippet
+	if err := json.Unmarshal(stripJSONC(buf.Bytes()), &got); err != nil {
+		t.Fatalf("got invalid JSONC: %v", err)
+	}
+	if !reflect.DeepEqual(got, *snippet) {
+		t.Errorf("got %+v, want %+v", got, *snippet)
+	}
+	if comment := leadingComment(buf.Bytes()); comment != opts.Header {
+		t.Errorf("got header %q, want %q", comment, opts.Header)
+	}
+}
+
+func TestObjectKeys(t *testing.T) {
+	for _, tt := range []struct {
+		json    string
+		want    []string
+		wantErr bool
+	}{
+		{`{"b": 1, "a": {"c": 2}, "d": [3]}`, []string{"b", "a", "d"}, false},
+		{`{}`, nil, false},
+		{`{"a": 1, "a": 2}`, []string{"a", "a"}, false},
+		{`{"a": `, nil, true},
+		{``, nil, true},
+	} {
+		got, err := objectKeys([]byte(tt.json))
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("objectKeys(%s) = %q, %v, want %q", tt.json, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package snippet
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Languages maps file extensions to VS Code language identifiers, see
+// https://code.visualstudio.com/docs/languages/identifiers. Extensions not
+// listed here are their own language identifier.
+var Languages = map[string]string{
+	"bash": "shellscript",
+	"bat":  "bat",
+	"cc":   "cpp",
+	"cjs":  "javascript",
+	"cmd":  "bat",
+	"cs":   "csharp",
+	"cxx":  "cpp",
+	"erl":  "erlang",
+	"ex":   "elixir",
+	"exs":  "elixir",
+	"fs":   "fsharp",
+	"h":    "c",
+	"hpp":  "cpp",
+	"hs":   "haskell",
+	"htm":  "html",
+	"jl":   "julia",
+	"js":   "javascript",
+	"jsx":  "javascriptreact",
+	"kt":   "kotlin",
+	"kts":  "kotlin",
+	"m":    "objective-c",
+	"md":   "markdown",
+	"mjs":  "javascript",
+	"mk":   "makefile",
+	"pl":   "perl",
+	"ps1":  "powershell",
+	"py":   "python",
+	"rb":   "ruby",
+	"rs":   "rust",
+	"sh":   "shellscript",
+	"tex":  "latex",
+	"ts":   "typescript",
+	"tsx":  "typescriptreact",
+	"yml":  "yaml",
+	"zsh":  "shellscript",
+}
+
+// LangOf returns the language identifier of files with extension ext.
+func (o *Options) LangOf(ext string) string {
+	if lang, ok := o.LangMap[ext]; ok {
+		return lang
+	}
+	if lang, ok := Languages[ext]; ok {
+		return lang
+	}
+	return ext
+}
+
+// scopeOf returns the scope for files with extension ext.
+func (o *Options) scopeOf(ext string) string {
+	if scope, ok := o.ScopeMap[ext]; ok {
+		return scope
+	}
+	return o.Scope
+}
+
+// Language returns the language bucket of files named like pathName,
+// or "" if they are skipped.
+func (o *Options) Language(pathName string) (string, error) {
+	if o.DescFrom == "sidecar" && filepath.Ext(pathName) == DescExt {
+		return "", nil
+	}
+	if o.Aliases && filepath.Ext(pathName) == AliasesExt {
+		return "", nil
+	}
+	_, ext := o.split(pathName)
+	lang := o.DefaultLang
+	if ext != "" {
+		lang = o.LangOf(ext)
+	} else if o.DefaultLang == "" {
+		if o.NoExtError {
+			return "", fmt.Errorf("adding %s: %w", pathName, ErrNoExtension)
+		}
+		o.skip(pathName, "no extension")
+		return "", nil
+	}
+	if !o.included(lang) {
+		o.skip(pathName, "excluded extension")
+		return "", nil
+	}
+	return lang, nil
+}
+
+// included reports whether the files of language lang pass Exts and
+// ExcludeExts, which list extensions or languages.
+func (o *Options) included(lang string) bool {
+	if lang == "" {
+		return true
+	}
+	matches := func(list []string) bool {
+		for _, e := range list {
+			if o.LangOf(strings.TrimPrefix(e, ".")) == lang {
+				return true
+			}
+		}
+		return false
+	}
+	if len(o.Exts) > 0 && !matches(o.Exts) {
+		return false
+	}
+	return !matches(o.ExcludeExts)
+}
@@ -0,0 +1,219 @@
+package snippet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLanguageWithoutExtension(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		want     string
+		wantErr  error
+	}{
+		{"makefile skipped", Options{}, "templates/Makefile", "", nil},
+		{"makefile error", Options{NoExtError: true}, "templates/Makefile", "", ErrNoExtension},
+		{"dotfile", Options{}, "templates/.gitignore", "gitignore", nil},
+		{"dotfile noext", Options{Dotfiles: "noext"}, "templates/.gitignore", "", nil},
+		{"dotfile noext error", Options{Dotfiles: "noext", NoExtError: true}, "templates/.gitignore", "", ErrNoExtension},
+		{"go", Options{NoExtError: true}, "templates/main.go", "go", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Language(tt.pathName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got language %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddSnippetWithoutExtension(t *testing.T) {
+	s, _ := addFiles(t, Options{}, map[string]string{
+		"Makefile":   "all:\n",
+		".gitignore": "*.o\n",
+		"main.go":    "package main\n",
+	})
+	if got, want := s.Langs(), []string{"gitignore", "go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got languages %q, want %q", got, want)
+	}
+}
+
+func TestDefaultLang(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		defaultLang string
+		want        []string
+	}{
+		{"skipped", "", []string{"go.json"}},
+		{"default language", "dockerfile", []string{"dockerfile.json", "go.json"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, Options{DefaultLang: tt.defaultLang}, map[string]string{
+				"Dockerfile": "FROM scratch\n",
+				"main.go":    "package main\n",
+			})
+			outputs, err := s.Outputs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outputs.Names(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got files %q, want %q", got, tt.want)
+			}
+			if tt.defaultLang != "" {
+				if _, ok := (*outputs["dockerfile.json"])["Dockerfile"]; !ok {
+					t.Errorf("Dockerfile missing from dockerfile.json")
+				}
+			}
+		})
+	}
+}
+
+func TestLangOf(t *testing.T) {
+	for _, tt := range []struct {
+		ext     string
+		langMap map[string]string
+		want    string
+	}{
+		{"ts", nil, "typescript"},
+		{"py", nil, "python"},
+		{"jsx", nil, "javascriptreact"},
+		{"zig", nil, "zig"},
+		{"ts", map[string]string{"ts": "typescriptreact"}, "typescriptreact"},
+		{"tmpl", map[string]string{"tmpl": "go"}, "go"},
+	} {
+		opts := Options{LangMap: tt.langMap}
+		if got := opts.LangOf(tt.ext); got != tt.want {
+			t.Errorf("LangOf(%q) with %v: got %q, want %q", tt.ext, tt.langMap, got, tt.want)
+		}
+	}
+}
+
+func TestOutputsByLanguage(t *testing.T) {
+	s, _ := addFiles(t, Options{LangMap: map[string]string{"tmpl": "go"}}, map[string]string{
+		"app.ts":    "export {}\n",
+		"main.py":   "pass\n",
+		"build.zig": "const std = @import(\"std\");\n",
+		"page.tmpl": "{{.}}\n",
+		"main.go":   "package main\n",
+	})
+	outputs, err := s.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"go.json", "python.json", "typescript.json", "zig.json"}
+	if got := outputs.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+	if got := len(*outputs["go.json"]); got != 2 {
+		t.Errorf("got %d go snippets, want 2", got)
+	}
+}
+
+func TestExtensionFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"all", Options{}, []string{"go.json", "python.json", "typescript.json"}},
+		{"only", Options{Exts: []string{"go", ".ts"}}, []string{"go.json", "typescript.json"}},
+		{"only by language", Options{Exts: []string{"python"}}, []string{"python.json"}},
+		{"excluded", Options{ExcludeExts: []string{"py"}}, []string{"go.json", "typescript.json"}},
+		{"only and excluded", Options{Exts: []string{"go", "ts"}, ExcludeExts: []string{"ts"}}, []string{"go.json"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, tt.opts, map[string]string{
+				"app.ts":  "export {}\n",
+				"main.py": "pass\n",
+				"main.go": "package main\n",
+			})
+			outputs, err := s.Outputs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outputs.Names(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got files %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutNames(t *testing.T) {
+	files := map[string]string{
+		"app.js":    "export {}\n",
+		"view.jsx":  "export default () => null\n",
+		"main.go":   "package main\n",
+		"helper.js": "export {}\n",
+	}
+	for _, tt := range []struct {
+		name     string
+		outNames map[string]string
+		want     map[string]int
+	}{
+		{"default", nil, map[string]int{"go.json": 1, "javascript.json": 2, "javascriptreact.json": 1}},
+		{"renamed", map[string]string{"go": "golang"}, map[string]int{"golang.json": 1, "javascript.json": 2, "javascriptreact.json": 1}},
+		{"with extension", map[string]string{".go": "golang.jsonc"}, map[string]int{"golang.jsonc": 1, "javascript.json": 2, "javascriptreact.json": 1}},
+		{"merged", map[string]string{"js": "javascript", "jsx": "javascript"}, map[string]int{"go.json": 1, "javascript.json": 3}},
+		{"by language", map[string]string{"javascriptreact": "javascript.json"}, map[string]int{"go.json": 1, "javascript.json": 3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, Options{OutNames: tt.outNames}, files)
+			outputs, err := s.Outputs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for name, snippet := range outputs {
+				got[name] = len(*snippet)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Names colliding in a shared file are resolved with OnCollision.
+	s, _ := addFiles(t, Options{OutNames: map[string]string{"jsx": "javascript"}, OnCollision: "error"}, map[string]string{
+		"app.js":  "export {}\n",
+		"app.jsx": "export {}\n",
+	})
+	if _, err := s.Outputs(); !errors.Is(err, ErrCollision) {
+		t.Errorf("got error %v, want %v", err, ErrCollision)
+	}
+}
+
+func TestDotfiles(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opts     Options
+		pathName string
+		wantName string
+		wantLang string
+	}{
+		{"gitignore", Options{}, "templates/.gitignore", "gitignore", "gitignore"},
+		{"eslintrc", Options{}, "templates/.eslintrc.json", "eslintrc", "json"},
+		{"noext", Options{Dotfiles: "noext", DefaultLang: "plaintext"}, "templates/.gitignore", ".gitignore", "plaintext"},
+		{"noext with extension", Options{Dotfiles: "noext"}, "templates/.eslintrc.json", ".eslintrc", "json"},
+		{"lang map", Options{LangMap: map[string]string{"gitignore": "ignore"}}, ".gitignore", "gitignore", "ignore"},
+		{"regular file", Options{}, "templates/main.go", "main", "go"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, err := tt.opts.Language(tt.pathName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lang != tt.wantLang {
+				t.Errorf("got language %q, want %q", lang, tt.wantLang)
+			}
+			if name := tt.opts.Name(tt.pathName); name != tt.wantName {
+				t.Errorf("got name %q, want %q", name, tt.wantName)
+			}
+		})
+	}
+}
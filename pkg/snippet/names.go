@@ -0,0 +1,59 @@
+package snippet
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words splits name into words at separators and case changes, so that
+// "MyHTTPHelper_func" gives "My", "HTTP", "Helper" and "func".
+func words(name string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// applyCase transforms name according to nameCase. Path separators in
+// names from paths are kept, each element is transformed.
+func applyCase(name, nameCase string) string {
+	var transform func(string) string
+	switch nameCase {
+	case "lower":
+		transform = strings.ToLower
+	case "upper":
+		transform = strings.ToUpper
+	case "kebab":
+		transform = func(s string) string { return strings.ToLower(strings.Join(words(s), "-")) }
+	case "snake":
+		transform = func(s string) string { return strings.ToLower(strings.Join(words(s), "_")) }
+	default:
+		return name
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elems[i] = transform(elem)
+	}
+	return strings.Join(elems, "/")
+}
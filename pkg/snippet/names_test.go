@@ -0,0 +1,67 @@
+package snippet
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"MyHelperFunc", []string{"My", "Helper", "Func"}},
+		{"MyHTTPHelper_func", []string{"My", "HTTP", "Helper", "func"}},
+		{"my-helper func", []string{"my", "helper", "func"}},
+		{"parseJSON2", []string{"parse", "JSON2"}},
+		{"", nil},
+	} {
+		if got := words(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCase(t *testing.T) {
+	for _, tt := range []struct {
+		nameCase string
+		name     string
+		want     string
+	}{
+		{"keep", "MyHelperFunc", "MyHelperFunc"},
+		{"", "MyHelperFunc", "MyHelperFunc"},
+		{"lower", "MyHelperFunc", "myhelperfunc"},
+		{"upper", "MyHelperFunc", "MYHELPERFUNC"},
+		{"kebab", "MyHelperFunc", "my-helper-func"},
+		{"snake", "MyHelperFunc", "my_helper_func"},
+		{"kebab", "Go/MyHelperFunc", "go/my-helper-func"},
+		{"snake", "my-helper.test", "my_helper_test"},
+	} {
+		if got := applyCase(tt.name, tt.nameCase); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.nameCase, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameCaseCollision(t *testing.T) {
+	files := map[string]string{
+		"a/MyHelper.go": "package a\n",
+		"b/myhelper.go": "package b\n",
+	}
+	s, _ := addFiles(t, Options{NameCase: "lower", OnCollision: "rename"}, files)
+	if got, want := s.Lang("go").Keys(), []string{"myhelper", "myhelper-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	s = New(Options{NameCase: "lower", OnCollision: "error"})
+	_, dir := addFiles(t, Options{}, files)
+	err := s.AddSnippet(filepath.Join(dir, "a", "MyHelper.go"))
+	if err == nil {
+		err = s.AddSnippet(filepath.Join(dir, "b", "myhelper.go"))
+	}
+	if !errors.Is(err, ErrCollision) {
+		t.Errorf("got error %v, want %v", err, ErrCollision)
+	}
+}
@@ -0,0 +1,308 @@
+// Package snippet generates VS Code snippet files from existing files: each
+// file becomes a snippet whose body is the file content, grouped into one
+// snippet file per language.
+//
+// A Snippets collects the snippets generated with its Options and writes
+// them:
+//
+//	s := snippet.New(snippet.Options{DescFrom: "firstline"})
+//	if err := s.AddSnippet("templates/retry.go"); err != nil {
+//		return err
+//	}
+//	return s.Write(ctx, dir)
+package snippet
+
+import (
+	"io/fs"
+	"regexp"
+)
+
+// Options control how files become snippets and how snippet files are
+// written. The zero value gives the defaults documented on each field.
+type Options struct {
+	// PrefixTemplate is the template of snippet prefixes, with the
+	// {name}, {dir} and {ext} placeholders; "{name}" if empty.
+	PrefixTemplate string
+	// PrefixWithExt appends the file extension to prefixes, as in
+	// client.go, unless PrefixTemplate already has {ext}.
+	PrefixWithExt bool
+	// PrefixNamespace is prepended to every prefix, separator included,
+	// as in "mylib.". Snippet names are left unchanged.
+	PrefixNamespace string
+	// Aliases adds the words in FILE.aliases sidecar files as additional
+	// prefixes.
+	Aliases bool
+	// DescFrom is the description source: "sidecar" (FILE.desc, falling
+	// back to "firstline"), "firstline" (the leading comment), "doc" (the
+	// leading comment block in the syntax of the language, removed from
+	// the body) or "none", the default.
+	DescFrom string
+	// DescPath describes the snippets left without description by their
+	// path relative to RelativeTo, the current directory if empty.
+	DescPath   bool
+	RelativeTo string
+	// DescTemplate, if set, describes them instead with this template, with
+	// the {name} and {ext} placeholders of PrefixTemplate, {path}, the path
+	// of DescPath, {size}, the file size in bytes, and {mtime}, its
+	// modification time in the DescTimeFormat layout, time.RFC3339 if empty.
+	DescTemplate   string
+	DescTimeFormat string
+	// DescMaxLen truncates descriptions longer than this many characters
+	// with an ellipsis, if positive. Descriptions are always collapsed to a
+	// single line without control characters.
+	DescMaxLen int
+	// Scope is the scope of the snippets, overridden per extension by
+	// ScopeMap.
+	Scope    string
+	ScopeMap map[string]string
+
+	// NameFrom is the snippet name source: "base", the file name without
+	// extension and the default, or "path", the path without extension,
+	// relative to StripPrefix if set.
+	NameFrom    string
+	StripPrefix string
+	// NameTemplate, if set, is the template of snippet names instead,
+	// with the {name}, {dir} and {ext} placeholders of PrefixTemplate and
+	// {relpath}, the path of NameFrom "path" with its / separators
+	// replaced by PathSep, "/" if empty.
+	NameTemplate string
+	PathSep      string
+	// NameCase is the snippet name case: "keep", the default, "lower",
+	// "upper", "kebab" or "snake".
+	NameCase string
+	// Rename maps snippet names, as derived from the options above, to
+	// the names to use instead. Collisions follow OnCollision.
+	Rename map[string]string
+	// OnCollision is what to do with a snippet name already taken:
+	// "error", "overwrite", the default, or "rename".
+	OnCollision string
+
+	// LangMap overrides Languages, the extension to language identifier
+	// mapping.
+	LangMap map[string]string
+	// Dotfiles is how dotfiles are named: "lang", the default, drops
+	// their leading dot and makes dotfiles without extension, such as
+	// .gitignore, their own language; "noext" keeps them as they are, so
+	// that .gitignore is a file without extension.
+	Dotfiles string
+	// DefaultLang is the language of files without extension; they are
+	// skipped if empty, or rejected with ErrNoExtension under NoExtError.
+	DefaultLang string
+	NoExtError  bool
+	// Exts, if not empty, lists the only extensions or languages to
+	// process. ExcludeExts lists the ones to skip.
+	Exts        []string
+	ExcludeExts []string
+	// MaxSize skips files larger than this many bytes, if positive.
+	MaxSize int64
+	// IncludeBinary includes files that do not look like text.
+	IncludeBinary bool
+	// RequireMatch, if set, skips the files whose content it does not
+	// match, and ExcludeMatch the ones whose content it matches.
+	RequireMatch *regexp.Regexp
+	ExcludeMatch *regexp.Regexp
+
+	// Directives maps the names of the "@name: value" directives read from
+	// the comments at the top of files, and removed from their bodies, to
+	// the MetadataKeys they set. Directives override frontmatter.
+	Directives map[string]string
+	// SplitOn, if set, splits every file at the lines it matches into a
+	// snippet per section, named by its first capture group.
+	SplitOn *regexp.Regexp
+
+	// DedupBodies keeps only the first snippet, in name order, of the
+	// snippets of a language with identical bodies: "first" drops the
+	// others and "merge" adds their prefixes to it. Empty keeps them all.
+	DedupBodies string
+
+	// Transforms rewrite the content of files, in order, before the
+	// other body options apply.
+	Transforms []BodyTransform
+	// Escape escapes $, } and \ in bodies so VS Code inserts them
+	// literally.
+	Escape bool
+	// TabstopMarker, if set, rewrites its matches in bodies into $N
+	// tabstops, N being its first capture group.
+	TabstopMarker *regexp.Regexp
+	// TrimBlankLines removes the leading and trailing blank lines of
+	// bodies, keeping the ones in between.
+	TrimBlankLines bool
+	// TrimTrailingSpace removes the trailing spaces and tabs of body
+	// lines.
+	TrimTrailingSpace bool
+	// KeepTrailingNewline ends the bodies of files ending with newlines
+	// with an empty line.
+	KeepTrailingNewline bool
+	// PreserveCRLF keeps the carriage returns of CRLF line endings.
+	PreserveCRLF bool
+	// ExpandTabs expands tabs to tab stops every ExpandTabs columns, if
+	// positive.
+	ExpandTabs int
+	// Wrap, if positive, splits the body lines longer than Wrap characters
+	// into pieces of at most Wrap characters, each ending with WrapMarker
+	// but the last, without splitting escapes, tabstops, placeholders and
+	// variables. VS Code joins body lines with newlines: wrapping changes the
+	// inserted text, so that it is only meant for long lines read in the
+	// snippet files rather than inserted as is.
+	Wrap       int
+	WrapMarker string
+	// Dedent removes the leading whitespace common to the lines of
+	// bodies, so that they are inserted at the cursor indentation.
+	Dedent bool
+	// FileTemplate marks the snippets as file templates, offered when
+	// creating a new file; frontmatter can override it per file with
+	// isFileTemplate.
+	FileTemplate bool
+	// Context, if set, is stored as the x-context field of the snippets,
+	// documenting their intended when-clause context for other tools.
+	Context string
+	// Fence wraps bodies in ``` Markdown code fences for their language.
+	Fence bool
+	// BodyStyle is the body encoding: "array" of lines, the default, or
+	// "auto", a string for single-line bodies.
+	BodyStyle string
+
+	// Indent maps languages to the indentation of their snippet files.
+	// The empty language holds the default, four spaces if missing.
+	Indent map[string]string
+	// SortBy is the order of the snippets in snippet files: "name", the
+	// default, or "prefix", their first prefix.
+	SortBy string
+	// OutputFormat is the encoding of snippet files: "json", the
+	// default, "yaml" or "toml", which also give their extension. Merge
+	// only reads JSON files.
+	OutputFormat string
+	// Header is a comment written at the top of every snippet file; lines
+	// are separated by newlines.
+	Header string
+	// Format is the output format: "global", LANG.json user snippets and
+	// the default, or "workspace", LANG.code-snippets always scoped.
+	Format string
+	// OutNames maps extensions or languages to the name of their snippet
+	// file, LANG.json or LANG.code-snippets by default. The languages
+	// sharing a file have their snippets merged.
+	OutNames map[string]string
+	// Split writes every snippet into its own LANG/NAME.json file, or
+	// LANG/NAME.code-snippets in the workspace format.
+	Split bool
+	// Single, if set, writes all the snippets, scoped to their language,
+	// into a single snippet file of this name.
+	Single string
+	// GroupBy is how snippets are grouped into snippet files: "ext", by
+	// language and the default, or "dir", into a DIR.code-snippets file per
+	// top-level directory, relative to StripPrefix or to the directory the
+	// file was added from, with every entry scoped. Files at the top level
+	// are grouped under the name of their directory. Split and Single take
+	// precedence.
+	GroupBy string
+	// Merge merges the snippets into the existing snippet files,
+	// resolving conflicts with OnCollision. Existing entries keep their
+	// position and new ones are added at the end. Existing files may have
+	// comments and trailing commas; only their leading comment is kept.
+	Merge bool
+	// ValidateSchema checks the existing snippet files read by Merge, and
+	// the merged content, against SnippetsSchema, failing with ErrSchema.
+	ValidateSchema bool
+	// AppendOnly only adds the snippets missing from the existing snippet
+	// files, reporting the others, at their end: the existing content is
+	// kept byte for byte. Files with nothing to add are not written.
+	AppendOnly bool
+	// SkipUnchanged leaves the snippet files that already have the
+	// content to write untouched.
+	SkipUnchanged bool
+	// DirMode and FileMode are the permissions of the directories and
+	// snippet files created, before the umask: 0755 and 0666 if zero.
+	DirMode  fs.FileMode
+	FileMode fs.FileMode
+	// Strict fails on the snippets VS Code would not load or offer, with
+	// ErrInvalid: the ones without prefix or body and, in the workspace
+	// format, without scope.
+	Strict bool
+	// Force makes read-only snippet files writable to overwrite them.
+	Force bool
+	// Prune marks the written snippets as generated and, under Merge,
+	// removes the generated snippets of the existing files that are no
+	// longer generated. Snippets written by hand are kept.
+	Prune bool
+
+	// Clean removes the snippet files previously written by Write that
+	// are no longer generated. The files written are listed in the
+	// OwnedFilesName file of the directory, so that files written by hand
+	// or by other tools are kept. Write refuses to clean when there are no
+	// snippets at all.
+	Clean bool
+
+	// Logger receives the progress of the generation; nil discards it.
+	// Logger and OnSkip are called concurrently when files are added
+	// concurrently.
+	Logger Logger
+	// OnSkip, if set, is called with the files left out by the extension,
+	// size, binary and content filters, and the reason why.
+	OnSkip func(pathName, reason string)
+}
+
+// Logger receives the messages of the generation.
+type Logger interface {
+	// Warnf reports a problem that does not stop the generation, such as
+	// a skipped file.
+	Warnf(format string, args ...interface{})
+	// Infof reports a summary of the written files.
+	Infof(format string, args ...interface{})
+	// Verbosef reports progress: each file added and written.
+	Verbosef(format string, args ...interface{})
+}
+
+// DefaultIndent is the indentation of snippet files when Options.Indent has
+// no default.
+const DefaultIndent = "    "
+
+func (o *Options) warnf(format string, args ...interface{}) {
+	if o.Logger != nil {
+		o.Logger.Warnf(format, args...)
+	}
+}
+
+func (o *Options) infof(format string, args ...interface{}) {
+	if o.Logger != nil {
+		o.Logger.Infof(format, args...)
+	}
+}
+
+func (o *Options) verbosef(format string, args ...interface{}) {
+	if o.Logger != nil {
+		o.Logger.Verbosef(format, args...)
+	}
+}
+
+// DirPerm returns the permissions of the directories created for o, DirMode
+// or 0755 if unset.
+func (o *Options) DirPerm() fs.FileMode {
+	if o.DirMode != 0 {
+		return o.DirMode
+	}
+	return 0755
+}
+
+func (o *Options) fileMode() fs.FileMode {
+	if o.FileMode != 0 {
+		return o.FileMode
+	}
+	return 0666
+}
+
+func (o *Options) skip(pathName, reason string) {
+	if o.OnSkip != nil {
+		o.OnSkip(pathName, reason)
+	}
+}
+
+// IndentFor returns the indentation of the snippet file of language lang.
+func (o *Options) IndentFor(lang string) string {
+	if indent, ok := o.Indent[lang]; ok {
+		return indent
+	}
+	if indent, ok := o.Indent[""]; ok {
+		return indent
+	}
+	return DefaultIndent
+}
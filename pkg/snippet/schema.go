@@ -0,0 +1,139 @@
+package snippet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SnippetsSchema is the JSON schema of VS Code snippet files, as VS Code
+// validates them: an object of snippets, each with a body.
+const SnippetsSchema = `{
+  "type": "object",
+  "additionalProperties": {
+    "type": "object",
+    "required": ["body"],
+    "properties": {
+      "prefix": {"type": ["string", "array"], "items": {"type": "string"}},
+      "body": {"type": ["string", "array"], "items": {"type": "string"}},
+      "description": {"type": ["string", "array"], "items": {"type": "string"}},
+      "scope": {"type": "string"},
+      "isFileTemplate": {"type": "boolean"}
+    }
+  }
+}`
+
+// schema is the subset of JSON schema SnippetsSchema uses.
+type schema struct {
+	Type                 types              `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+}
+
+// types is the type of a schema: a type name or a list of them.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = types{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+var snippetsSchema = func() *schema {
+	var s schema
+	if err := json.Unmarshal([]byte(SnippetsSchema), &s); err != nil {
+		panic(err)
+	}
+	return &s
+}()
+
+// validateSchema returns an ErrSchema error listing the violations of
+// SnippetsSchema in v, the decoded JSON of the snippet file fileName, by
+// JSON pointer.
+func validateSchema(fileName string, v interface{}) error {
+	var violations []string
+	snippetsSchema.validate(v, "#", &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %w: %s", fileName, ErrSchema, strings.Join(violations, "; "))
+}
+
+// validate appends to violations the ways v, at pointer, violates s.
+func (s *schema) validate(v interface{}, pointer string, violations *[]string) {
+	typ := typeOf(v)
+	if len(s.Type) > 0 && !s.Type.has(typ) {
+		*violations = append(*violations, fmt.Sprintf("%s: expected %s, got %s", pointer, strings.Join(s.Type, " or "), typ))
+		return
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s: missing %q", pointer, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			property := s.Properties[k]
+			if property == nil {
+				property = s.AdditionalProperties
+			}
+			if property != nil {
+				property.validate(v[k], pointer+"/"+escapePointer(k), violations)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s/%d", pointer, i), violations)
+			}
+		}
+	}
+}
+
+func (t types) has(typ string) bool {
+	for _, name := range t {
+		if name == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON schema type of v, decoded by encoding/json.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// escapePointer escapes key as a JSON pointer reference token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
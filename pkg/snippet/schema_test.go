@@ -0,0 +1,81 @@
+package snippet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"valid", `{"loop": {"prefix": "loop", "body": ["for {}"], "description": "Loop", "scope": "go", "isFileTemplate": false}}`, nil},
+		{"string body", `{"loop": {"prefix": ["loop", "for"], "body": "for {}"}}`, nil},
+		{"unknown properties", `{"loop": {"body": "for {}", "x-generated": true}}`, nil},
+		{"empty", `{}`, nil},
+		{"not an object", `[]`, []string{"#: expected object, got array"}},
+		{"snippet not an object", `{"loop": "for {}"}`, []string{"#/loop: expected object, got string"}},
+		{"no body", `{"loop": {"prefix": "loop"}}`, []string{`#/loop: missing "body"`}},
+		{"body item", `{"loop": {"body": ["for {", 1, "}"]}}`, []string{"#/loop/body/1: expected string, got number"}},
+		{"scope", `{"loop": {"body": "x", "scope": ["go"]}}`, []string{"#/loop/scope: expected string, got array"}},
+		{"file template", `{"loop": {"body": "x", "isFileTemplate": "yes"}}`, []string{"#/loop/isFileTemplate: expected boolean, got string"}},
+		{"null prefix", `{"loop": {"body": "x", "prefix": null}}`, []string{"#/loop/prefix: expected string or array, got null"}},
+		{"escaped pointer", `{"a/b~c": {}}`, []string{`#/a~1b~0c: missing "body"`}},
+		{"several", `{"b": {}, "a": {"body": true}}`, []string{"#/a/body: expected string or array, got boolean", `#/b: missing "body"`}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tt.json), &v); err != nil {
+				t.Fatal(err)
+			}
+			err := validateSchema("go.json", v)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSchema) {
+				t.Fatalf("got error %v, want %v", err, ErrSchema)
+			}
+			if want := "go.json: " + ErrSchema.Error() + ": " + strings.Join(tt.want, "; "); err.Error() != want {
+				t.Errorf("got error %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestMergeValidateSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{"valid", `{"old": {"prefix": "old", "body": ["old"]}}`, ""},
+		{"malformed", `{"old": {"prefix": "old", "body": [1]}}`, "#/old/body/0: expected string, got number"},
+		{"comments", "// mine\n{\"old\": {\"prefix\": \"old\"},}", `#/old: missing "body"`},
+		{"invalid json", `{"old": `, "decoding"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, Options{Merge: true, ValidateSchema: true}, map[string]string{"loop.go": "for {}\n"})
+			out := t.TempDir()
+			writeFiles(t, out, map[string]string{"go.json": tt.existing})
+			err := s.Write(context.Background(), out)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), filepath.Join(out, "go.json")) {
+				t.Errorf("got error %v, want it to name the file and %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package snippet
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// section is a delimited part of a multi-snippet file.
+type section struct {
+	name string
+	body []byte
+}
+
+// splitSections splits b at the lines matching delim into the sections
+// following them, named by the first capture group of delim, or by the
+// whole match without one. The content before the first delimiter is
+// dropped.
+func splitSections(delim *regexp.Regexp, b []byte) []section {
+	var sections []section
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		m := delim.FindSubmatch(bytes.TrimRight(line, "\r\n"))
+		if m == nil {
+			if n := len(sections); n > 0 {
+				sections[n-1].body = append(sections[n-1].body, line...)
+			}
+			continue
+		}
+		name := m[0]
+		if len(m) > 1 {
+			name = m[1]
+		}
+		sections = append(sections, section{name: strings.TrimSpace(string(name)), body: []byte{}})
+	}
+	return sections
+}
+
+// sectionEntries returns a snippet for every section of the file at
+// pathName delimited by SplitOn. Sections are generated as files named after
+// them, with the extension of pathName and in its directory.
+func (o *Options) sectionEntries(fsys fs.FS, pathName string, b []byte) ([]entry, error) {
+	_, ext := o.split(pathName)
+	var entries []entry
+	for _, sec := range splitSections(o.SplitOn, b) {
+		if sec.name == "" {
+			o.warnf("skipping unnamed section of %s", pathName)
+			continue
+		}
+		name := sec.name
+		if ext != "" {
+			name += "." + ext
+		}
+		key, file, err := o.fileOf(fsys, filepath.Join(filepath.Dir(pathName), name), sec.body)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, file})
+	}
+	return entries, nil
+}
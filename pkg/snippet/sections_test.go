@@ -0,0 +1,61 @@
+package snippet
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSplitOn(t *testing.T) {
+	delim := regexp.MustCompile(`^=== (.*) ===$`)
+	const content = "preamble, dropped\n" +
+		"=== loop ===\nfor {}\n" +
+		"=== retry ===\n// Retries.\nfor i := 0; i < 3; i++ {\n}\n" +
+		"=== wait ===\r\nselect {}\r\n"
+	s := New(Options{SplitOn: delim, DescFrom: "firstline"})
+	if err := s.AddReader("snippets/all.go", strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for k, file := range *s.Lang("go") {
+		got[k] = file.Prefix[0] + "|" + file.Description + "|" + strings.Join(file.Body, "\n")
+	}
+	want := map[string]string{
+		"loop":  "loop||for {}",
+		"retry": "retry|Retries.|// Retries.\nfor i := 0; i < 3; i++ {\n}",
+		"wait":  "wait||select {}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		delim   string
+		content string
+		want    []section
+	}{
+		{"capture", `^# (\w+)$`, "# a\n1\n# b\n2\n", []section{{"a", []byte("1\n")}, {"b", []byte("2\n")}}},
+		{"whole match", `^\[\w+\]$`, "[a]\n1\n", []section{{"[a]", []byte("1\n")}}},
+		{"empty section", `^# (\w*)$`, "# a\n# b\n2", []section{{"a", []byte{}}, {"b", []byte("2")}}},
+		{"none", `^# (\w+)$`, "1\n2\n", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitSections(regexp.MustCompile(tt.delim), []byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Unnamed sections are skipped.
+	s := New(Options{SplitOn: regexp.MustCompile(`^# (\w*)$`)})
+	if err := s.AddReader("all.go", strings.NewReader("# \nskipped\n# kept\nfor {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Lang("go").Keys(); !reflect.DeepEqual(got, []string{"kept"}) {
+		t.Errorf("got %q, want only the named section", got)
+	}
+}
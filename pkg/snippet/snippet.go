@@ -0,0 +1,224 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// Snippet holds the snippets of a snippet file, keyed by name.
+type Snippet map[string]*File
+
+// AddFile adds the snippet of the file at pathName with the content of r,
+// generated with opts, or its snippets under SplitOn. Files too large,
+// binary or filtered out by their content are skipped.
+func (s *Snippet) AddFile(opts *Options, pathName string, r io.Reader) error {
+	return s.addFile(opts, osFS{}, pathName, r)
+}
+
+// addFile is AddFile reading the sidecar files of pathName from fsys.
+func (s *Snippet) addFile(opts *Options, fsys fs.FS, pathName string, r io.Reader) error {
+	b, err := opts.readContent(pathName, r)
+	if err != nil || b == nil {
+		return err
+	}
+	return s.addContent(opts, fsys, pathName, b)
+}
+
+// addContent adds the snippets of the file at pathName with content b.
+func (s *Snippet) addContent(opts *Options, fsys fs.FS, pathName string, b []byte) error {
+	entries, err := opts.entriesOf(fsys, pathName, b)
+	if err != nil {
+		return err
+	}
+	return s.addEntries(opts, pathName, entries)
+}
+
+// entry is a snippet generated from a file, and its key.
+type entry struct {
+	key  string
+	file *File
+}
+
+// entriesOf returns the snippets of the file at pathName with content b:
+// its snippet or, under SplitOn, the ones of its sections.
+func (o *Options) entriesOf(fsys fs.FS, pathName string, b []byte) ([]entry, error) {
+	if o.SplitOn != nil {
+		return o.sectionEntries(fsys, pathName, b)
+	}
+	key, file, err := o.fileOf(fsys, pathName, b)
+	if err != nil {
+		return nil, err
+	}
+	return []entry{{key, file}}, nil
+}
+
+// addEntries adds entries, the snippets of the file at pathName.
+func (s *Snippet) addEntries(opts *Options, pathName string, entries []entry) error {
+	for _, e := range entries {
+		if err := s.Add(e.key, e.file, opts.OnCollision); err != nil {
+			return fmt.Errorf("adding %s: %w", pathName, err)
+		}
+	}
+	return nil
+}
+
+// Add stores file under key, resolving an existing key according to
+// onCollision: "error" fails with ErrCollision, "rename" stores file under
+// the first free key-N, starting from 2, and otherwise file replaces the
+// existing snippet.
+func (s *Snippet) Add(key string, file *File, onCollision string) error {
+	if _, ok := (*s)[key]; ok {
+		switch onCollision {
+		case "error":
+			return fmt.Errorf("%w: %s", ErrCollision, key)
+		case "rename":
+			for n := 2; ; n++ {
+				renamed := fmt.Sprintf("%s-%d", key, n)
+				if _, ok := (*s)[renamed]; !ok {
+					key = renamed
+					break
+				}
+			}
+		}
+	}
+	(*s)[key] = file
+	return nil
+}
+
+// renamedAs returns the key under which Add with "rename" put a snippet with
+// the prefix and body of file: key or one of its renamings, or "" if there
+// is no such snippet.
+func (s Snippet) renamedAs(key string, file *File) string {
+	for n, k := 2, key; ; n++ {
+		existing, ok := s[k]
+		if !ok {
+			return ""
+		}
+		if equalStrings(existing.Prefix, file.Prefix) && equalStrings(existing.Body, file.Body) {
+			return k
+		}
+		k = fmt.Sprintf("%s-%d", key, n)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Keys returns the keys of s, sorted.
+func (s Snippet) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scoped returns a copy of snippet where entries without a scope are scoped
+// to lang.
+func scoped(lang string, snippet *Snippet) *Snippet {
+	copied := make(Snippet, len(*snippet))
+	for k, file := range *snippet {
+		f := *file
+		if f.Scope == "" {
+			f.Scope = lang
+		}
+		copied[k] = &f
+	}
+	return &copied
+}
+
+// keysByPrefix returns the keys of s sorted by the first prefix of their
+// snippet, then by key.
+func (s Snippet) keysByPrefix() []string {
+	keys := s.Keys()
+	first := func(k string) string {
+		if prefix := s[k].Prefix; len(prefix) > 0 {
+			return prefix[0]
+		}
+		return ""
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return first(keys[i]) < first(keys[j])
+	})
+	return keys
+}
+
+// orderedSnippet encodes its entries as a JSON object in the order of keys.
+type orderedSnippet struct {
+	keys    []string
+	entries map[string]interface{}
+}
+
+func (s orderedSnippet) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range s.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(s.entries[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// encodable returns the value encoding snippet according to BodyStyle and
+// keys, the order of its entries, or SortBy if nil.
+func (o *Options) encodable(snippet *Snippet, keys []string) interface{} {
+	if keys == nil && o.SortBy == "prefix" {
+		keys = snippet.keysByPrefix()
+	}
+	if o.BodyStyle != "auto" && keys == nil {
+		return snippet
+	}
+	entries := make(map[string]interface{}, len(*snippet))
+	for k, file := range *snippet {
+		if o.BodyStyle == "auto" {
+			entries[k] = compactFile(*file)
+			continue
+		}
+		entries[k] = file
+	}
+	if keys == nil {
+		return entries
+	}
+	return orderedSnippet{keys: keys, entries: entries}
+}
+
+// EncodeIndent writes the JSON encoding of snippet to w, indented with
+// indent.
+func (o *Options) EncodeIndent(w io.Writer, snippet *Snippet, indent string) error {
+	return o.encodeJSON(w, snippet, nil, indent)
+}
+
+// encodeJSON is EncodeIndent writing the entries in the order of keys, or
+// SortBy if nil.
+func (o *Options) encodeJSON(w io.Writer, snippet *Snippet, keys []string, indent string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", indent)
+	return enc.Encode(o.encodable(snippet, keys))
+}
@@ -0,0 +1,137 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollision(t *testing.T) {
+	for _, tt := range []struct {
+		onCollision string
+		wantErr     error
+		want        map[string]string
+	}{
+		{"error", ErrCollision, nil},
+		{"overwrite", nil, map[string]string{"utils": "package c"}},
+		{"", nil, map[string]string{"utils": "package c"}},
+		{"rename", nil, map[string]string{"utils": "package a", "utils-2": "package b", "utils-3": "package c"}},
+	} {
+		t.Run(tt.onCollision, func(t *testing.T) {
+			s := New(Options{OnCollision: tt.onCollision})
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"a/utils.go": "package a\n",
+				"b/utils.go": "package b\n",
+				"c/utils.go": "package c\n",
+			})
+			var err error
+			for _, sub := range []string{"a", "b", "c"} {
+				if err = s.AddSnippet(filepath.Join(dir, sub, "utils.go")); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got := map[string]string{}
+			for k, file := range *s.Lang("go") {
+				got[k] = file.Body[0]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyStyle(t *testing.T) {
+	for _, tt := range []struct {
+		bodyStyle string
+		body      Body
+		want      string
+	}{
+		{"array", Body{"one"}, `["one"]`},
+		{"", Body{"one"}, `["one"]`},
+		{"auto", Body{"one"}, `"one"`},
+		{"auto", Body{"one", "two"}, `["one","two"]`},
+		{"auto", Body{}, `[]`},
+	} {
+		opts := Options{BodyStyle: tt.bodyStyle}
+		var buf bytes.Buffer
+		if err := opts.EncodeIndent(&buf, &Snippet{"s": {Prefix: Prefix{"s"}, Body: tt.body}}, ""); err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(decoded["s"]["body"]); got != tt.want {
+			t.Errorf("%q with %q: got body %s, want %s", tt.bodyStyle, tt.body, got, tt.want)
+		}
+		// Both encodings decode to the same body.
+		decodedSnippet := Snippet{}
+		if err := json.Unmarshal(buf.Bytes(), &decodedSnippet); err != nil {
+			t.Fatal(err)
+		}
+		if len(tt.body) > 0 && !reflect.DeepEqual(decodedSnippet["s"].Body, tt.body) {
+			t.Errorf("%q with %q: decoded body %q", tt.bodyStyle, tt.body, decodedSnippet["s"].Body)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	snippet := &Snippet{
+		"a-loop":  {Prefix: Prefix{"zloop"}, Body: Body{"for {}"}},
+		"b-retry": {Prefix: Prefix{"retry", "again"}, Body: Body{"retry()"}},
+		"c-wait":  {Prefix: Prefix{"awaits"}, Body: Body{"select {}"}},
+		"d-empty": {Body: Body{"empty"}},
+		"e-same":  {Prefix: Prefix{"retry"}, Body: Body{"retry()"}},
+	}
+	for _, tt := range []struct {
+		sortBy string
+		want   []string
+	}{
+		{"", []string{"a-loop", "b-retry", "c-wait", "d-empty", "e-same"}},
+		{"name", []string{"a-loop", "b-retry", "c-wait", "d-empty", "e-same"}},
+		{"prefix", []string{"d-empty", "c-wait", "b-retry", "e-same", "a-loop"}},
+	} {
+		for _, bodyStyle := range []string{"array", "auto"} {
+			opts := Options{SortBy: tt.sortBy, BodyStyle: bodyStyle}
+			var buf bytes.Buffer
+			if err := opts.EncodeIndent(&buf, snippet, "  "); err != nil {
+				t.Fatal(err)
+			}
+			got, err := objectKeys(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%q with %s bodies: got %q, want %q", tt.sortBy, bodyStyle, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestOrderedSnippet(t *testing.T) {
+	s := orderedSnippet{
+		keys:    []string{"b", "a<"},
+		entries: map[string]interface{}{"a<": 1, "b": []string{"x"}},
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":["x"],"a\u003c":1}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	if b, err := json.Marshal(orderedSnippet{}); err != nil || string(b) != "{}" {
+		t.Errorf("got %s, error %v", b, err)
+	}
+}
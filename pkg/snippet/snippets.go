@@ -0,0 +1,795 @@
+package snippet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CodeSnippetsExt is the extension of snippet files holding several
+// languages.
+const CodeSnippetsExt = ".code-snippets"
+
+// Snippets holds the snippets of several languages, generated with the same
+// options. It is safe for concurrent use, as long as Options is not changed.
+type Snippets struct {
+	Options Options
+
+	mu    sync.RWMutex
+	langs map[string]*Snippet
+	files int
+}
+
+// New returns an empty Snippets generating snippets with opts.
+func New(opts Options) *Snippets {
+	return &Snippets{Options: opts, langs: map[string]*Snippet{}}
+}
+
+// AddSnippet adds the file at pathName.
+func (s *Snippets) AddSnippet(pathName string) error {
+	if lang, err := s.Options.Language(pathName); lang == "" {
+		return err
+	}
+	f, err := os.Open(pathName)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadFailed, pathName, err)
+	}
+	defer f.Close()
+	return s.AddReader(pathName, f)
+}
+
+// AddReader adds a snippet named after pathName with the content of r.
+func (s *Snippets) AddReader(pathName string, r io.Reader) error {
+	return s.addReader(osFS{}, "", pathName, r)
+}
+
+// AddReaderIn is AddReader for a file found in the directory root, which
+// its GroupBy "dir" group is relative to unless StripPrefix is set.
+func (s *Snippets) AddReaderIn(root, pathName string, r io.Reader) error {
+	return s.addReader(osFS{}, root, pathName, r)
+}
+
+// addReader is AddReaderIn reading the sidecar files of pathName from fsys.
+func (s *Snippets) addReader(fsys fs.FS, root, pathName string, r io.Reader) error {
+	lang, err := s.Options.Language(pathName)
+	if lang == "" {
+		return err
+	}
+	s.Options.verbosef("adding %s to %s", pathName, lang)
+	// The snippets are generated before taking the lock, which is only held
+	// to add them.
+	b, err := s.Options.readContent(pathName, r)
+	if err != nil || b == nil {
+		return err
+	}
+	entries, err := s.Options.entriesOf(fsys, pathName, b)
+	if err != nil {
+		return err
+	}
+	if s.Options.GroupBy == "dir" {
+		for _, e := range entries {
+			e.file.group = s.Options.groupOf(root, pathName)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snippet, ok := s.langs[lang]
+	if !ok {
+		snippet = &Snippet{}
+	}
+	if err := snippet.addEntries(&s.Options, pathName, entries); err != nil {
+		return err
+	}
+	// Under SplitOn, files without sections add no snippets.
+	if len(entries) > 0 {
+		s.files++
+	}
+	// AddFile may skip the file; languages without snippets are left out.
+	if len(*snippet) > 0 {
+		s.langs[lang] = snippet
+	}
+	return nil
+}
+
+// Lang returns the snippets of language lang, or nil if there are none.
+func (s *Snippets) Lang(lang string) *Snippet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.langs[lang]
+}
+
+// FileCount returns the number of files the snippets of s were generated
+// from.
+func (s *Snippets) FileCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.files
+}
+
+// Langs returns the languages of s, sorted.
+func (s *Snippets) Langs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.langNames()
+}
+
+// langNames is Langs without locking.
+func (s *Snippets) langNames() []string {
+	langs := make([]string, 0, len(s.langs))
+	for lang := range s.langs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Outputs maps snippet file names to their content.
+type Outputs map[string]*Snippet
+
+// Count returns the number of snippets in o.
+func (o Outputs) Count() int {
+	n := 0
+	for _, snippet := range o {
+		n += len(*snippet)
+	}
+	return n
+}
+
+// Names returns the file names of o, sorted.
+func (o Outputs) Names() []string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outputs returns the snippet files to write, keyed by file name: one per
+// language, one per snippet with Split, one per directory with GroupBy "dir"
+// or, with Single, a single file with all of them. Under Strict, the
+// snippets are validated.
+func (s *Snippets) Outputs() (Outputs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	outputs, err := s.outputs()
+	if err != nil || !s.Options.Strict {
+		return outputs, err
+	}
+	if err := s.Options.validate(outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// outputs is Outputs without locking nor validation.
+func (s *Snippets) outputs() (Outputs, error) {
+	if s.Options.Single != "" {
+		combined, err := s.combined()
+		if err != nil {
+			return nil, err
+		}
+		return Outputs{s.Options.singleName(): combined}, nil
+	}
+	if s.Options.Split {
+		return s.split()
+	}
+	if s.Options.GroupBy == "dir" {
+		return s.grouped()
+	}
+	outputs := make(Outputs, len(s.langs))
+	for _, lang := range s.langNames() {
+		snippet, ext := s.lang(lang), s.Options.fileExt(".json")
+		if s.Options.Format == "workspace" {
+			snippet, ext = scoped(lang, snippet), s.Options.fileExt(CodeSnippetsExt)
+		}
+		name := s.Options.outName(lang, ext)
+		existing, ok := outputs[name]
+		if !ok {
+			outputs[name] = snippet
+			continue
+		}
+		// Several languages share the file: their entries are merged in
+		// language order.
+		merged := make(Snippet, len(*existing)+len(*snippet))
+		for k, file := range *existing {
+			merged[k] = file
+		}
+		for _, k := range snippet.Keys() {
+			if err := merged.Add(k, (*snippet)[k], s.Options.OnCollision); err != nil {
+				return nil, fmt.Errorf("combining %s snippets into %s: %w", lang, name, err)
+			}
+		}
+		outputs[name] = &merged
+	}
+	return outputs, nil
+}
+
+// split returns the Split outputs: a LANG/NAME file per snippet. Names
+// leading out of the LANG directory, as paths with .. elements, are
+// rejected.
+func (s *Snippets) split() (Outputs, error) {
+	ext := s.Options.fileExt(".json")
+	if s.Options.Format == "workspace" {
+		ext = s.Options.fileExt(CodeSnippetsExt)
+	}
+	outputs := Outputs{}
+	for lang := range s.langs {
+		snippet := s.lang(lang)
+		if s.Options.Format == "workspace" {
+			snippet = scoped(lang, snippet)
+		}
+		for k, file := range *snippet {
+			if !filepath.IsLocal(k) {
+				return nil, fmt.Errorf("splitting %s snippets: %q is not a local file name", lang, k)
+			}
+			outputs[lang+"/"+k+ext] = &Snippet{k: file}
+		}
+	}
+	return outputs, nil
+}
+
+// grouped returns the GroupBy "dir" outputs: a DIR file per group, with
+// the snippets scoped to their language.
+func (s *Snippets) grouped() (Outputs, error) {
+	outputs := Outputs{}
+	for _, lang := range s.langNames() {
+		snippet := *scoped(lang, s.lang(lang))
+		for _, k := range snippet.Keys() {
+			name := s.Options.groupName(snippet[k])
+			group, ok := outputs[name]
+			if !ok {
+				group = &Snippet{}
+				outputs[name] = group
+			}
+			if err := group.Add(k, snippet[k], s.Options.OnCollision); err != nil {
+				return nil, fmt.Errorf("combining %s snippets into %s: %w", lang, name, err)
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// groupName returns the name of the GroupBy "dir" snippet file of file.
+func (o *Options) groupName(file *File) string {
+	return file.group + o.fileExt(CodeSnippetsExt)
+}
+
+// outName returns the name of the snippet file of language lang: the one
+// set in OutNames, with ext added if it has no extension, or lang+ext.
+func (o *Options) outName(lang, ext string) string {
+	for _, key := range sortedKeys(o.OutNames) {
+		if o.LangOf(strings.TrimPrefix(key, ".")) != lang {
+			continue
+		}
+		name := o.OutNames[key]
+		if filepath.Ext(name) == "" {
+			name += ext
+		}
+		return name
+	}
+	return lang + ext
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// singleName returns the file name of the Single output.
+func (o *Options) singleName() string {
+	if filepath.Ext(o.Single) == "" {
+		return o.Single + o.fileExt(CodeSnippetsExt)
+	}
+	return o.Single
+}
+
+// Combined returns all the snippets in a single Snippet. Entries without a
+// scope are scoped to their language.
+func (s *Snippets) Combined() (*Snippet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.combined()
+}
+
+// combined is Combined without locking.
+func (s *Snippets) combined() (*Snippet, error) {
+	combined := Snippet{}
+	for _, lang := range s.langNames() {
+		snippet := *scoped(lang, s.lang(lang))
+		for _, k := range snippet.Keys() {
+			if err := combined.Add(k, snippet[k], s.Options.OnCollision); err != nil {
+				return nil, fmt.Errorf("combining %s snippets: %w", lang, err)
+			}
+		}
+	}
+	return &combined, nil
+}
+
+// Write writes the snippet files into pathName, in file name order. The
+// snippets within each file are sorted by key, as encoding/json does with
+// maps, or by prefix according to SortBy, so that the output is
+// reproducible. Under Clean, the files written by the previous Write that
+// are no longer generated are removed first.
+func (s *Snippets) Write(ctx context.Context, pathName string) error {
+	outputs, err := s.Outputs()
+	if err != nil {
+		return err
+	}
+	if s.Options.Clean {
+		if len(outputs) == 0 {
+			return fmt.Errorf("no snippets generated: not cleaning %s", pathName)
+		}
+		if err := s.Options.clean(pathName, outputs); err != nil {
+			return err
+		}
+	}
+	files, count := 0, 0
+	for _, name := range outputs.Names() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := s.Options.writeFile(pathName, name, outputs[name])
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			files++
+			count += n
+		}
+	}
+	if s.Options.Clean {
+		if err := s.Options.writeOwnedFiles(pathName, outputs); err != nil {
+			return err
+		}
+	}
+	// Files left as they were, up to date or with nothing to append, are
+	// not counted.
+	if files > 0 {
+		s.Options.infof("Wrote %d snippets across %d snippet files to %s", count, files, pathName)
+	}
+	return nil
+}
+
+// Files returns the content of the snippet files, keyed by file name, as
+// Write would write them without Merge.
+func (s *Snippets) Files() (map[string][]byte, error) {
+	outputs, err := s.Outputs()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(outputs))
+	for name, snippet := range outputs {
+		var buf bytes.Buffer
+		if err := s.Options.Encode(&buf, name, snippet); err != nil {
+			return nil, fmt.Errorf("encoding %s: %w", name, err)
+		}
+		files[name] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// WriteFile writes snippet into the snippet file name of the directory dir,
+// merging it with the existing content of the file under Merge. The
+// directories of Split outputs are created as needed. name must be a local
+// path, within dir.
+func (o *Options) WriteFile(dir, name string, snippet *Snippet) error {
+	_, err := o.writeFile(dir, name, snippet)
+	return err
+}
+
+// writeFile is WriteFile returning the number of snippets written: those
+// of snippet or, under AppendOnly, the ones appended; 0 if the file was
+// left as it was.
+func (o *Options) writeFile(dir, name string, snippet *Snippet) (int, error) {
+	fileName := filepath.Join(dir, name)
+	if !filepath.IsLocal(name) {
+		return 0, fmt.Errorf("%w %s: outside of %s", ErrWriteFailed, fileName, dir)
+	}
+	if o.Split {
+		if err := os.MkdirAll(filepath.Dir(fileName), o.DirPerm()); err != nil {
+			return 0, fmt.Errorf("%w %s: creating: %w", ErrWriteFailed, filepath.Dir(fileName), err)
+		}
+	}
+	if o.AppendOnly {
+		content, added, exists, err := o.appended(fileName, name, snippet)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			if written, err := o.writeAppended(fileName, content); !written {
+				return 0, err
+			}
+			return added, nil
+		}
+	}
+	final, keys, header, err := o.finalize(fileName, snippet)
+	if err != nil {
+		return 0, err
+	}
+	if written, err := o.writeOne(fileName, name, final, keys, header); !written {
+		return 0, err
+	}
+	return len(*snippet), nil
+}
+
+// finalize returns the content to write into fileName for snippet: snippet
+// itself or, under Merge, snippet merged into the existing content, the
+// order of its keys, nil for the SortBy order, and the header to write above
+// it. Without Header, merged files keep their leading comment.
+func (o *Options) finalize(fileName string, snippet *Snippet) (final *Snippet, keys []string, header string, err error) {
+	if o.Prune {
+		snippet = generated(snippet)
+	}
+	if !o.Merge {
+		return snippet, nil, o.Header, nil
+	}
+	merged, keys, comment, err := o.mergeInto(fileName, snippet)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if o.ValidateSchema {
+		if err := o.validateMerged(fileName, merged); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	if o.Header != "" {
+		return merged, keys, o.Header, nil
+	}
+	return merged, keys, comment, nil
+}
+
+// validateMerged checks the encoding of merged, the content to write into
+// fileName, against SnippetsSchema.
+func (o *Options) validateMerged(fileName string, merged *Snippet) error {
+	b, err := json.Marshal(o.encodable(merged, nil))
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", fileName, err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("encoding %s: %w", fileName, err)
+	}
+	return validateSchema(fileName+" (merged)", v)
+}
+
+// generated returns a copy of snippet with its entries marked as generated.
+func generated(snippet *Snippet) *Snippet {
+	copied := make(Snippet, len(*snippet))
+	for k, file := range *snippet {
+		f := *file
+		f.Generated = true
+		copied[k] = &f
+	}
+	return &copied
+}
+
+// Check returns the files in pathName whose content differs from what Write
+// would write, including the missing ones.
+func (s *Snippets) Check(pathName string) ([]string, error) {
+	outputs, err := s.Outputs()
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, name := range outputs.Names() {
+		fileName := filepath.Join(pathName, name)
+		var want bytes.Buffer
+		exists := false
+		if s.Options.AppendOnly {
+			content, _, ok, err := s.Options.appended(fileName, name, outputs[name])
+			if err != nil {
+				return nil, err
+			}
+			if exists = ok; ok && content == nil {
+				continue
+			}
+			want.Write(content)
+		}
+		if !exists {
+			final, keys, header, err := s.Options.finalize(fileName, outputs[name])
+			if err != nil {
+				return nil, err
+			}
+			if err := s.Options.encode(&want, name, final, keys, header); err != nil {
+				return nil, fmt.Errorf("encoding %s: %w", fileName, err)
+			}
+		}
+		got, err := os.ReadFile(fileName)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			stale = append(stale, fileName)
+		}
+	}
+	return stale, nil
+}
+
+// mergeInto returns the snippets in the existing fileName with snippet added
+// to them, the order of their keys and the leading comment of the file.
+// Existing keys that snippet does not define are preserved, unless they were
+// generated and Prune is set. Existing keys keep their position and new ones
+// follow in the SortBy order; keys is nil if there is no fileName. The file
+// is read as JSON with comments and trailing commas, as VS Code does; only
+// its leading comment is kept.
+func (o *Options) mergeInto(fileName string, snippet *Snippet) (merged *Snippet, keys []string, comment string, err error) {
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return snippet, nil, "", nil
+	}
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+	}
+
+	existing := Snippet{}
+	stripped := stripJSONC(b)
+	if o.ValidateSchema {
+		// The schema is checked first, the decoding errors of Snippet
+		// being less helpful.
+		var v interface{}
+		if err := json.Unmarshal(stripped, &v); err != nil {
+			return nil, nil, "", fmt.Errorf("decoding %s: %w", fileName, err)
+		}
+		if err := validateSchema(fileName, v); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	if err := json.Unmarshal(stripped, &existing); err != nil {
+		return nil, nil, "", fmt.Errorf("decoding %s: %w", fileName, err)
+	}
+	fileKeys, err := objectKeys(stripped)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("decoding %s: %w", fileName, err)
+	}
+
+	if o.Prune {
+		// The generated entries still generated are added back below.
+		for k, file := range existing {
+			if file.Generated {
+				delete(existing, k)
+			}
+		}
+	}
+	for _, k := range snippet.Keys() {
+		// Under "rename", a snippet merged before replaces its earlier
+		// version instead of being renamed again.
+		if o.OnCollision == "rename" {
+			if same := existing.renamedAs(k, (*snippet)[k]); same != "" {
+				existing[same] = (*snippet)[k]
+				continue
+			}
+		}
+		if err := existing.Add(k, (*snippet)[k], o.OnCollision); err != nil {
+			return nil, nil, "", fmt.Errorf("merging into %s: %w", fileName, err)
+		}
+	}
+
+	seen := make(map[string]bool, len(existing))
+	keys = make([]string, 0, len(existing))
+	for _, k := range append(fileKeys, o.orderedKeys(&existing, nil)...) {
+		if _, ok := existing[k]; ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return &existing, keys, leadingComment(b), nil
+}
+
+// appended returns the content of the existing fileName with the entries of
+// snippet it does not have added at its end, the snippet file name, and the
+// number of entries added. The existing content is kept byte for byte;
+// content is nil if there is nothing to add. exists is false if there is no
+// fileName.
+func (o *Options) appended(fileName, name string, snippet *Snippet) (content []byte, added int, exists bool, err error) {
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("%w %s: %w", ErrReadFailed, fileName, err)
+	}
+	existing := Snippet{}
+	if err := json.Unmarshal(stripJSONC(b), &existing); err != nil {
+		return nil, 0, true, fmt.Errorf("decoding %s: %w", fileName, err)
+	}
+
+	missing := Snippet{}
+	for _, k := range snippet.Keys() {
+		if _, ok := existing[k]; ok {
+			o.warnf("skipping %s: already in %s", k, fileName)
+			continue
+		}
+		missing[k] = (*snippet)[k]
+	}
+	if len(missing) == 0 {
+		return nil, 0, true, nil
+	}
+
+	// The entries are inserted before the brace closing the file, and a
+	// comma after the last entry unless it already has one.
+	end := bytes.LastIndexByte(b, '}')
+	if end < 0 || len(bytes.TrimSpace(stripJSONC(b[end+1:]))) > 0 {
+		return nil, 0, true, fmt.Errorf("appending to %s: no closing brace", fileName)
+	}
+	var entries bytes.Buffer
+	if err := o.EncodeIndent(&entries, &missing, o.IndentFor(o.outputLang(name))); err != nil {
+		return nil, 0, true, fmt.Errorf("encoding %s: %w", fileName, err)
+	}
+	inner := bytes.TrimSpace(entries.Bytes())
+	inner = bytes.Trim(inner[1:len(inner)-1], "\r\n")
+
+	head := bytes.TrimRight(b[:end], " \t\r\n")
+	last := lastToken(head)
+	if last >= 0 && head[last] != '{' && head[last] != ',' {
+		content = append(content, head[:last+1]...)
+		content = append(content, ',')
+		content = append(content, head[last+1:]...)
+	} else {
+		content = append(content, head...)
+	}
+	content = append(content, '\n')
+	content = append(content, inner...)
+	content = append(content, '\n')
+	return append(content, b[end:]...), len(missing), true, nil
+}
+
+// writeAppended writes content, the result of appended, into fileName, if
+// there is anything appended, reporting whether it did.
+func (o *Options) writeAppended(fileName string, content []byte) (bool, error) {
+	if content == nil {
+		o.verbosef("%s has no new snippets", fileName)
+		return false, nil
+	}
+	f, err := o.create(fileName)
+	if err != nil {
+		return false, fmt.Errorf("%w %s: creating: %w", ErrWriteFailed, fileName, err)
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return false, fmt.Errorf("%w %s: writing: %w", ErrWriteFailed, fileName, err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("%w %s: closing: %w", ErrWriteFailed, fileName, err)
+	}
+	o.verbosef("appended snippets to %s", fileName)
+	return true, nil
+}
+
+// DryRun prints to w the files Write would create in pathName and the
+// number of snippets in each.
+func (s *Snippets) DryRun(w io.Writer, pathName string) error {
+	outputs, err := s.Outputs()
+	if err != nil {
+		return err
+	}
+	for _, name := range outputs.Names() {
+		fileName := filepath.Join(pathName, name)
+		if _, err := fmt.Fprintf(w, "%s: %d snippets\n", fileName, len(*outputs[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeOutputs writes to w the JSON encoding of outputs, a single object
+// keyed by file name.
+func (o *Options) EncodeOutputs(w io.Writer, outputs Outputs) error {
+	encodable := make(map[string]interface{}, len(outputs))
+	for name, snippet := range outputs {
+		encodable[name] = o.encodable(snippet, nil)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", o.IndentFor(""))
+	return enc.Encode(encodable)
+}
+
+// Encode writes the content of the snippet file name for snippet to w: the
+// Header comment, if any, followed by its encoding in OutputFormat, indented
+// for the language of the file. VS Code reads snippet files as JSON with
+// comments.
+func (o *Options) Encode(w io.Writer, name string, snippet *Snippet) error {
+	return o.encode(w, name, snippet, nil, o.Header)
+}
+
+// encode is Encode writing header instead of Header, and the entries in the
+// order of keys unless nil.
+func (o *Options) encode(w io.Writer, name string, snippet *Snippet, keys []string, header string) error {
+	if header != "" {
+		for _, line := range strings.Split(header, "\n") {
+			if _, err := fmt.Fprintln(w, strings.TrimRight(o.commentMarker()+" "+line, " ")); err != nil {
+				return err
+			}
+		}
+	}
+	indent := o.IndentFor(o.outputLang(name))
+	switch o.OutputFormat {
+	case "yaml":
+		return o.encodeYAML(w, snippet, keys, indent)
+	case "toml":
+		return o.encodeTOML(w, snippet, keys, indent)
+	}
+	return o.encodeJSON(w, snippet, keys, indent)
+}
+
+// outputLang returns the language of the snippet file name: its directory
+// under Split, its base name otherwise.
+func (o *Options) outputLang(name string) string {
+	if o.Split {
+		lang, _, _ := strings.Cut(filepath.ToSlash(name), "/")
+		return lang
+	}
+	lang, _ := splitName(filepath.Base(name))
+	return lang
+}
+
+// writeOne encodes snippet, the snippet file name, into fileName below
+// header, in the order of keys unless nil, closing it before returning. It
+// reports whether it wrote fileName, which SkipUnchanged leaves as it is
+// when up to date.
+func (o *Options) writeOne(fileName, name string, snippet *Snippet, keys []string, header string) (bool, error) {
+	if o.SkipUnchanged {
+		var want bytes.Buffer
+		if err := o.encode(&want, name, snippet, keys, header); err != nil {
+			return false, fmt.Errorf("%w %s: encoding: %w", ErrWriteFailed, fileName, err)
+		}
+		if got, err := os.ReadFile(fileName); err == nil && bytes.Equal(got, want.Bytes()) {
+			o.verbosef("%s is up to date", fileName)
+			return false, nil
+		}
+	}
+
+	f, err := o.create(fileName)
+	if err != nil {
+		return false, fmt.Errorf("%w %s: creating: %w", ErrWriteFailed, fileName, err)
+	}
+
+	if err := o.encode(f, name, snippet, keys, header); err != nil {
+		f.Close()
+		return false, fmt.Errorf("%w %s: encoding: %w", ErrWriteFailed, fileName, err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("%w %s: closing: %w", ErrWriteFailed, fileName, err)
+	}
+	o.verbosef("wrote %d snippets to %s", len(*snippet), fileName)
+	return true, nil
+}
+
+// create creates, with FileMode, or truncates fileName. Under Force,
+// read-only files are made writable first.
+func (o *Options) create(fileName string) (*os.File, error) {
+	f, err := o.openFile(fileName)
+	if !errors.Is(err, fs.ErrPermission) {
+		return f, err
+	}
+	info, statErr := os.Stat(fileName)
+	if statErr != nil || info.Mode().Perm()&0200 != 0 {
+		return nil, err
+	}
+	if !o.Force {
+		return nil, ErrReadOnly
+	}
+	if err := os.Chmod(fileName, info.Mode().Perm()|0200); err != nil {
+		return nil, err
+	}
+	o.verbosef("made %s writable", fileName)
+	return o.openFile(fileName)
+}
+
+func (o *Options) openFile(fileName string) (*os.File, error) {
+	return os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, o.fileMode())
+}
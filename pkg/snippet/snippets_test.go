@@ -0,0 +1,773 @@
+package snippet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnippetsConcurrentAdd(t *testing.T) {
+	dir := t.TempDir()
+	exts := []string{"go", "py", "js"}
+	const perExt = 50
+	var paths []string
+	for _, ext := range exts {
+		for i := 0; i < perExt; i++ {
+			pathName := filepath.Join(dir, fmt.Sprintf("snippet%d.%s", i, ext))
+			if err := os.WriteFile(pathName, []byte(fmt.Sprintf("line %d\n", i)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, pathName)
+		}
+	}
+
+	s := New(Options{})
+	var wg sync.WaitGroup
+	errs := make(chan error, len(paths))
+	for _, pathName := range paths {
+		wg.Add(1)
+		go func(pathName string) {
+			defer wg.Done()
+			errs <- s.AddSnippet(pathName)
+		}(pathName)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	langs := s.Langs()
+	if len(langs) != len(exts) {
+		t.Fatalf("got languages %v, want %d", langs, len(exts))
+	}
+	for _, lang := range langs {
+		if n := len(*s.Lang(lang)); n != perExt {
+			t.Errorf("%s: got %d snippets, want %d", lang, n, perExt)
+		}
+	}
+	outputs, err := s.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := outputs.Count(); n != len(paths) {
+		t.Errorf("got %d snippets in the outputs, want %d", n, len(paths))
+	}
+}
+
+// writeFiles creates the files, keyed by path relative to dir, with their
+// content.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// addFiles returns the Snippets of the files, written into a temporary
+// directory and added in path order.
+func addFiles(t *testing.T, opts Options, files map[string]string) (*Snippets, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	s := New(opts)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := s.AddSnippet(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, dir
+}
+
+func TestWriteManyLanguages(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 300; i++ {
+		files[fmt.Sprintf("snippet.ext%d", i)] = "body\n"
+	}
+	s, _ := addFiles(t, Options{}, files)
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		b, err := os.ReadFile(filepath.Join(out, fmt.Sprintf("ext%d.json", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(b) {
+			t.Fatalf("ext%d.json: invalid JSON %q", i, b)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	s, _ := addFiles(t, Options{}, map[string]string{"loop.go": "for {}\n"})
+	out := t.TempDir()
+	// A directory where the snippet file goes cannot be created.
+	if err := os.Mkdir(filepath.Join(out, "go.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(context.Background(), out); !errors.Is(err, ErrWriteFailed) {
+		t.Errorf("got error %v, want ErrWriteFailed", err)
+	}
+}
+
+func TestAddReader(t *testing.T) {
+	s := New(Options{})
+	if err := s.AddReader("gen.go", strings.NewReader("line 1\nline 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	file := (*s.Lang("go"))["gen"]
+	if file == nil {
+		t.Fatal("no gen snippet")
+	}
+	if want := (Body{"line 1", "line 2"}); !reflect.DeepEqual(file.Body, want) {
+		t.Errorf("got body %q, want %q", file.Body, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	const existing = `{
+    "manual": {"prefix": "manual", "description": "by hand", "body": ["hand"]},
+    "loop": {"prefix": "loop", "description": "old", "body": ["old"]}
+}`
+	for _, tt := range []struct {
+		onCollision string
+		wantErr     error
+		want        map[string]string
+	}{
+		{"overwrite", nil, map[string]string{"manual": "hand", "loop": "for {}", "retry": "retry()"}},
+		{"rename", nil, map[string]string{"manual": "hand", "loop": "old", "loop-2": "for {}", "retry": "retry()"}},
+		{"error", ErrCollision, nil},
+	} {
+		t.Run(tt.onCollision, func(t *testing.T) {
+			s, _ := addFiles(t, Options{Merge: true, OnCollision: tt.onCollision}, map[string]string{
+				"loop.go":  "for {}\n",
+				"retry.go": "retry()\n",
+			})
+			out := t.TempDir()
+			writeFiles(t, out, map[string]string{"go.json": existing})
+			err := s.Write(context.Background(), out)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got := map[string]string{}
+			for k, file := range readSnippets(t, filepath.Join(out, "go.json")) {
+				got[k] = strings.Join(file.Body, "\n")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeRenameStable(t *testing.T) {
+	const existing = `{
+    "loop": {"prefix": "loop", "description": "old", "body": ["old"]}
+}`
+	s, _ := addFiles(t, Options{Merge: true, OnCollision: "rename"}, map[string]string{
+		"loop.go":  "for {}\n",
+		"retry.go": "retry()\n",
+	})
+	out := t.TempDir()
+	writeFiles(t, out, map[string]string{"go.json": existing})
+	fileName := filepath.Join(out, "go.json")
+	var first []byte
+	for i := 0; i < 2; i++ {
+		if err := s.Write(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = b
+		} else if !bytes.Equal(b, first) {
+			t.Errorf("second merge changed the file from:\n%s\nto:\n%s", first, b)
+		}
+	}
+	want := []string{"loop", "loop-2", "retry"}
+	if got := readSnippets(t, fileName).Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+}
+
+func TestMergeWithoutExistingFile(t *testing.T) {
+	s, _ := addFiles(t, Options{Merge: true}, map[string]string{"loop.go": "for {}\n"})
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSnippets(t, filepath.Join(out, "go.json")).Keys(); !reflect.DeepEqual(got, []string{"loop"}) {
+		t.Errorf("got keys %q", got)
+	}
+}
+
+// readSnippets decodes the JSON snippet file fileName.
+func readSnippets(t *testing.T, fileName string) Snippet {
+	t.Helper()
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Snippet{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("decoding %s: %v", fileName, err)
+	}
+	return s
+}
+
+func TestWriteCancelled(t *testing.T) {
+	s, _ := addFiles(t, Options{}, map[string]string{"loop.go": "for {}\n", "loop.py": "pass\n"})
+	out := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Write(ctx, out); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) > 0 {
+		t.Errorf("cancelled Write wrote %d files", len(entries))
+	}
+}
+
+func TestSingle(t *testing.T) {
+	for _, tt := range []struct {
+		single   string
+		wantName string
+	}{
+		{"all", "all.code-snippets"},
+		{"all.json", "all.json"},
+	} {
+		t.Run(tt.single, func(t *testing.T) {
+			s, _ := addFiles(t, Options{Single: tt.single, ScopeMap: map[string]string{"ts": "typescript,javascript"}}, map[string]string{
+				"loop.go": "for {}\n",
+				"main.py": "pass\n",
+				"app.ts":  "export {}\n",
+			})
+			outputs, err := s.Outputs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outputs.Names(); !reflect.DeepEqual(got, []string{tt.wantName}) {
+				t.Fatalf("got files %q, want %s", got, tt.wantName)
+			}
+			got := map[string]string{}
+			for k, file := range *outputs[tt.wantName] {
+				got[k] = file.Scope
+			}
+			want := map[string]string{"loop": "go", "main": "python", "app": "typescript,javascript"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got scopes %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceFormat(t *testing.T) {
+	s, _ := addFiles(t, Options{Format: "workspace"}, map[string]string{
+		"loop.go": "for {}\n",
+		"main.py": "pass\n",
+	})
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	for name, scope := range map[string]string{"go.code-snippets": "go", "python.code-snippets": "python"} {
+		for k, file := range readSnippets(t, filepath.Join(out, name)) {
+			if file.Scope != scope {
+				t.Errorf("%s: %s has scope %q, want %q", name, k, file.Scope, scope)
+			}
+		}
+	}
+}
+
+func TestWriteDeterministic(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 40; i++ {
+		files[fmt.Sprintf("s%02d.%s", i, []string{"go", "py", "js"}[i%3])] = fmt.Sprintf("line %d\n", i)
+	}
+	var written [2]map[string]string
+	for i := range written {
+		s, _ := addFiles(t, Options{}, files)
+		out := t.TempDir()
+		if err := s.Write(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+		written[i] = map[string]string{}
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			b, err := os.ReadFile(filepath.Join(out, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			written[i][entry.Name()] = string(b)
+		}
+	}
+	if !reflect.DeepEqual(written[0], written[1]) {
+		t.Error("two generations wrote different snippet files")
+	}
+	// Keys are sorted within files.
+	b := written[0]["go.json"]
+	if i, j := strings.Index(b, `"s00"`), strings.Index(b, `"s03"`); i < 0 || j < i {
+		t.Errorf("go.json keys are not sorted: %s", b)
+	}
+}
+
+func TestIndentByLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		indent map[string]string
+		want   map[string]string
+	}{
+		{"default", nil, map[string]string{"go.json": DefaultIndent, "python.json": DefaultIndent}},
+		{"default only", map[string]string{"": "  "}, map[string]string{"go.json": "  ", "python.json": "  "}},
+		{"per language", map[string]string{"": "  ", "python": "\t"}, map[string]string{"go.json": "  ", "python.json": "\t"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, Options{Indent: tt.indent}, map[string]string{
+				"loop.go": "for {}\n",
+				"loop.py": "while True: pass\n",
+			})
+			out := t.TempDir()
+			if err := s.Write(context.Background(), out); err != nil {
+				t.Fatal(err)
+			}
+			for name, indent := range tt.want {
+				b, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "{\n" + indent + `"loop"`; !strings.HasPrefix(string(b), want) {
+					t.Errorf("%s: got %q, want it to start with %q", name, b, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFiles(t *testing.T) {
+	s, dir := addFiles(t, Options{}, map[string]string{
+		"retry.go": "for {}\n",
+		"wait.go":  "select {}\n",
+		"loop.py":  "while True: pass\n",
+	})
+	files, err := s.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Snippet{
+		"go.json": {
+			"retry": {Prefix: Prefix{"retry"}, Body: Body{"for {}"}},
+			"wait":  {Prefix: Prefix{"wait"}, Body: Body{"select {}"}},
+		},
+		"python.json": {
+			"loop": {Prefix: Prefix{"loop"}, Body: Body{"while True: pass"}},
+		},
+	}
+	got := map[string]Snippet{}
+	for name, b := range files {
+		var snippet Snippet
+		if err := json.Unmarshal(b, &snippet); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got[name] = snippet
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 3 {
+		t.Errorf("Files wrote into %s: %v", dir, err)
+	}
+
+	// The bytes are the ones Write writes.
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range files {
+		written, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(written) != string(b) {
+			t.Errorf("%s: got %q, Write wrote %q", name, b, written)
+		}
+	}
+}
+
+func TestEncodeOutputs(t *testing.T) {
+	s, _ := addFiles(t, Options{}, map[string]string{
+		"retry.go": "for {}\n",
+		"loop.py":  "while True: pass\n",
+	})
+	outputs, err := s.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := s.Options.EncodeOutputs(&buf, outputs); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]Snippet
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["go.json"]["retry"] == nil || got["python.json"]["loop"] == nil {
+		t.Errorf("got %s", buf.String())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	out := t.TempDir()
+	write := func(opts Options, files map[string]string) map[string]string {
+		t.Helper()
+		s, _ := addFiles(t, opts, files)
+		if err := s.Write(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for k, file := range readSnippets(t, filepath.Join(out, "go.json")) {
+			got[k] = strings.Join(file.Body, "\n")
+		}
+		return got
+	}
+	writeFiles(t, out, map[string]string{"go.json": `{"manual": {"prefix": "manual", "body": ["hand"]}}`})
+	opts := Options{Merge: true, Prune: true}
+	write(opts, map[string]string{"loop.go": "for {}\n", "retry.go": "retry()\n"})
+
+	// retry.go was deleted.
+	got := write(opts, map[string]string{"loop.go": "for {}\n"})
+	if want := map[string]string{"manual": "hand", "loop": "for {}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if file := readSnippets(t, filepath.Join(out, "go.json"))["manual"]; file.Generated {
+		t.Error("manual entry marked as generated")
+	}
+
+	// Without Prune, generated entries are kept.
+	write(opts, map[string]string{"loop.go": "for {}\n", "retry.go": "retry()\n"})
+	got = write(Options{Merge: true}, map[string]string{"loop.go": "for {}\n"})
+	if want := map[string]string{"manual": "hand", "loop": "for {}", "retry": "retry()"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q without Prune, want %q", got, want)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	s, _ := addFiles(t, Options{Split: true}, map[string]string{
+		"go/loop.go":  "for {}\n",
+		"go/retry.go": "retry()\n",
+		"py/loop.py":  "while True: pass\n",
+	})
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err := filepath.WalkDir(out, func(pathName string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(out, pathName)
+		got = append(got, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The loop snippets of both languages do not collide.
+	want := []string{"go/loop.json", "go/retry.json", "python/loop.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+	for name, body := range map[string]string{
+		"go/loop.json":     "for {}",
+		"go/retry.json":    "retry()",
+		"python/loop.json": "while True: pass",
+	} {
+		snippets := readSnippets(t, filepath.Join(out, filepath.FromSlash(name)))
+		if len(snippets) != 1 || strings.Join(snippets[strings.TrimSuffix(filepath.Base(name), ".json")].Body, "\n") != body {
+			t.Errorf("%s: got %+v, want only its snippet", name, snippets)
+		}
+	}
+}
+
+func TestSplitNotLocal(t *testing.T) {
+	s := New(Options{Split: true, NameFrom: "path"})
+	if err := s.AddReader("../loop.go", strings.NewReader("for {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Outputs(); err == nil {
+		t.Error("got no error for a snippet name outside of its directory")
+	}
+}
+
+func TestMergeJSONC(t *testing.T) {
+	const existing = `// My snippets.
+// Edited by hand.
+{
+    /* Kept as it is. */
+    "manual": {
+        "prefix": "manual", // trigger
+        "body": ["hand // not a comment",],
+    },
+    "loop": {"prefix": "loop", "body": ["old"]},
+}
+`
+	s, _ := addFiles(t, Options{Merge: true}, map[string]string{"loop.go": "for {}\n"})
+	out := t.TempDir()
+	writeFiles(t, out, map[string]string{"go.json": existing})
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "go.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "// My snippets.\n// Edited by hand.\n{") {
+		t.Errorf("got %q, want the leading comment kept", b)
+	}
+	got := Snippet{}
+	if err := json.Unmarshal(stripJSONC(b), &got); err != nil {
+		t.Fatal(err)
+	}
+	if body := strings.Join(got["manual"].Body, "\n"); body != "hand // not a comment" {
+		t.Errorf("got manual body %q", body)
+	}
+	if body := strings.Join(got["loop"].Body, "\n"); body != "for {}" {
+		t.Errorf("got loop body %q", body)
+	}
+	if keys, err := objectKeys(stripJSONC(b)); err != nil || !reflect.DeepEqual(keys, []string{"manual", "loop"}) {
+		t.Errorf("got keys %q, %v, want the existing order", keys, err)
+	}
+
+	// Header replaces the leading comment.
+	s.Options.Header = "Generated."
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(out, "go.json")); err != nil || !strings.HasPrefix(string(b), "// Generated.\n{") {
+		t.Errorf("got %q, %v, want the Header", b, err)
+	}
+}
+
+func TestAppendOnly(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		existing string
+		loop     string
+	}{
+		{"plain", "{\n    \"loop\": {\"prefix\": \"old\", \"body\": [\"old\"]}\n}\n", "old"},
+		{"trailing comma", "// Mine.\n{\n    \"loop\": {\"prefix\": \"old\", \"body\": [\"old\"]}, // hand-written\n}\n", "old"},
+		{"empty", "{}\n", "for {}"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+			s, _ := addFiles(t, Options{AppendOnly: true, Logger: logger}, map[string]string{
+				"loop.go":  "for {}\n",
+				"retry.go": "retry()\n",
+			})
+			out := t.TempDir()
+			writeFiles(t, out, map[string]string{"go.json": tt.existing})
+			if err := s.Write(context.Background(), out); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(filepath.Join(out, "go.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The existing content is kept byte for byte.
+			head := tt.existing[:strings.LastIndexByte(tt.existing, '}')]
+			head = strings.TrimRight(head, " \t\r\n")
+			if !strings.HasPrefix(string(b), strings.TrimSuffix(head, ",")) {
+				t.Errorf("got %q, want the existing content %q kept", b, tt.existing)
+			}
+			got := Snippet{}
+			if err := json.Unmarshal(stripJSONC(b), &got); err != nil {
+				t.Fatalf("got invalid JSONC %q: %v", b, err)
+			}
+			if retry := got["retry"]; retry == nil || strings.Join(retry.Body, "\n") != "retry()" {
+				t.Errorf("got %q, want retry appended", b)
+			}
+			if loop := got["loop"]; loop == nil || strings.Join(loop.Body, "\n") != tt.loop {
+				t.Errorf("got %q, want loop %q", b, tt.loop)
+			}
+			if skipped := tt.loop == "old"; skipped != (len(logger.warnings) == 1) {
+				t.Errorf("got warnings %q", logger.warnings)
+			}
+			// Only the entries appended are counted.
+			appended := 2
+			if tt.loop == "old" {
+				appended = 1
+			}
+			if want := []string{fmt.Sprintf("Wrote %d snippets across 1 snippet files to %s", appended, out)}; !reflect.DeepEqual(logger.infos, want) {
+				t.Errorf("got %q, want %q", logger.infos, want)
+			}
+
+			// Nothing left to append.
+			logger.infos = nil
+			if err := s.Write(context.Background(), out); err != nil {
+				t.Fatal(err)
+			}
+			if len(logger.infos) != 0 {
+				t.Errorf("got %q with nothing appended", logger.infos)
+			}
+			if again, err := os.ReadFile(filepath.Join(out, "go.json")); err != nil || string(again) != string(b) {
+				t.Errorf("got %q rewritten, want %q", again, b)
+			}
+		})
+	}
+
+	// New files are written in full.
+	s, _ := addFiles(t, Options{AppendOnly: true}, map[string]string{"loop.py": "pass\n"})
+	out := t.TempDir()
+	if err := s.Write(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSnippets(t, filepath.Join(out, "python.json")); got["loop"] == nil {
+		t.Errorf("got %+v, want loop", got)
+	}
+}
+
+func TestWriteModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not enforced on windows")
+	}
+	for _, tt := range []struct {
+		name      string
+		opts      Options
+		dir, file fs.FileMode
+	}{
+		// The umask never masks the permissions of the owner, and can only
+		// remove the others.
+		{"defaults", Options{Split: true, Clean: true}, 0755, 0666},
+		{"set", Options{Split: true, Clean: true, DirMode: 0700, FileMode: 0600}, 0700, 0600},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, tt.opts, map[string]string{"loop.go": "for {}\n"})
+			out := t.TempDir()
+			if err := s.Write(context.Background(), out); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range map[string]fs.FileMode{
+				filepath.Join(out, "go"):              tt.dir,
+				filepath.Join(out, "go", "loop.json"): tt.file,
+				filepath.Join(out, OwnedFilesName):    tt.file,
+			} {
+				info, err := os.Stat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := info.Mode().Perm(); got&^want != 0 || got&0700 != want&0700 {
+					t.Errorf("%s: got mode %#o, want %#o", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeKeepsOrder(t *testing.T) {
+	const existing = `{
+    "zeta": {"prefix": "zeta", "body": ["z"]},
+    "loop": {"prefix": "loop", "body": ["old"]},
+    "alpha": {"prefix": "alpha", "body": ["a"]},
+    "gen": {"prefix": "gen", "body": ["g"], "x-generated": true}
+}`
+	files := map[string]string{
+		"c/loop.go":  "for {}\n",
+		"a/retry.go": "retry()\n",
+		"b/again.go": "again()\n",
+	}
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		// Existing entries keep their position, replaced or not, and new
+		// ones are appended.
+		{"merge", Options{Merge: true}, []string{"zeta", "loop", "alpha", "gen", "again", "retry"}},
+		{"renamed", Options{Merge: true, OnCollision: "rename"}, []string{"zeta", "loop", "alpha", "gen", "again", "loop-2", "retry"}},
+		{"pruned", Options{Merge: true, Prune: true}, []string{"zeta", "loop", "alpha", "again", "retry"}},
+		{"by prefix", Options{Merge: true, SortBy: "prefix", NameTemplate: "{name}-{dir}", PrefixTemplate: "{dir}"}, []string{"zeta", "loop", "alpha", "gen", "retry-a", "again-b", "loop-c"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := addFiles(t, tt.opts, files)
+			out := t.TempDir()
+			writeFiles(t, out, map[string]string{"go.json": existing})
+			if err := s.Write(context.Background(), out); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(filepath.Join(out, "go.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := objectKeys(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCount(t *testing.T) {
+	var skipped []string
+	s, _ := addFiles(t, Options{
+		SplitOn:      regexp.MustCompile(`^=== (.+) ===$`),
+		RequireMatch: regexp.MustCompile("snippet"),
+		OnSkip:       func(pathName, reason string) { skipped = append(skipped, filepath.Base(pathName)) },
+	}, map[string]string{
+		"one.go":   "=== one ===\n// snippet\nfor {}\n",
+		"none.go":  "// snippet, without sections\n",
+		"two.go":   "=== a ===\n// snippet\n=== b ===\nb()\n",
+		"other.go": "no marker\n",
+		"README":   "snippet\n",
+		"loop.py":  "=== loop ===\n# snippet\npass\n",
+	})
+	if got := s.FileCount(); got != 3 {
+		t.Errorf("got %d files, want 3", got)
+	}
+	if got := len(*s.Lang("go")) + len(*s.Lang("python")); got != 4 {
+		t.Errorf("got %d snippets, want 4", got)
+	}
+	if want := []string{"README", "other.go"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("got skipped %q, want %q", skipped, want)
+	}
+	if got := New(Options{}).FileCount(); got != 0 {
+		t.Errorf("got %d files without any", got)
+	}
+}
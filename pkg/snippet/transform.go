@@ -0,0 +1,90 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// BodyTransform rewrites the content of a file before it becomes a snippet
+// body, after its frontmatter is removed.
+type BodyTransform interface {
+	Transform(b []byte) ([]byte, error)
+}
+
+// TransformFunc adapts a function to the BodyTransform interface.
+type TransformFunc func(b []byte) ([]byte, error)
+
+func (f TransformFunc) Transform(b []byte) ([]byte, error) {
+	return f(b)
+}
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]BodyTransform{
+		"trim":   linesTransform(trimBlankLines),
+		"dedent": linesTransform(dedent),
+	}
+)
+
+// linesTransform returns the BodyTransform applying f to the lines of the
+// content.
+func linesTransform(f func([]string) []string) BodyTransform {
+	return TransformFunc(func(b []byte) ([]byte, error) {
+		lines := bytes.Split(b, []byte("\n"))
+		text := make([]string, len(lines))
+		for i, line := range lines {
+			text[i] = string(line)
+		}
+		var buf bytes.Buffer
+		for i, line := range f(text) {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(line)
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// RegisterTransform registers t under name, replacing the transform
+// registered under name, if any. The built-in transforms are "trim", which
+// removes the leading and trailing blank lines, and "dedent", which removes
+// the indentation common to all the lines.
+func RegisterTransform(name string, t BodyTransform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = t
+}
+
+// LookupTransform returns the transform registered under name.
+func LookupTransform(name string) (BodyTransform, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	t, ok := transforms[name]
+	return t, ok
+}
+
+// TransformNames returns the names of the registered transforms, sorted.
+func TransformNames() []string {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// transform applies Transforms to the content b of the file at pathName.
+func (o *Options) transform(pathName string, b []byte) ([]byte, error) {
+	for _, t := range o.Transforms {
+		var err error
+		if b, err = t.Transform(b); err != nil {
+			return nil, fmt.Errorf("transforming %s: %w", pathName, err)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,70 @@
+package snippet
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	upper := TransformFunc(func(b []byte) ([]byte, error) {
+		return bytes.ToUpper(b), nil
+	})
+	license := TransformFunc(func(b []byte) ([]byte, error) {
+		return append([]byte("// MIT\n"), b...), nil
+	})
+	RegisterTransform("test-upper", upper)
+	t.Cleanup(func() {
+		transformsMu.Lock()
+		delete(transforms, "test-upper")
+		transformsMu.Unlock()
+	})
+	registered, ok := LookupTransform("test-upper")
+	if !ok {
+		t.Fatal("test-upper not registered")
+	}
+	trim, _ := LookupTransform("trim")
+	dedent, _ := LookupTransform("dedent")
+
+	for _, tt := range []struct {
+		name       string
+		transforms []BodyTransform
+		content    string
+		want       Body
+	}{
+		{"none", nil, "\n  a\n", Body{"", "  a"}},
+		{"trim", []BodyTransform{trim}, "\n\n  a\n\n", Body{"  a"}},
+		{"dedent", []BodyTransform{dedent}, "    a\n      b\n", Body{"a", "  b"}},
+		{"custom", []BodyTransform{registered}, "for {}\n", Body{"FOR {}"}},
+		{"in order", []BodyTransform{license, registered}, "for {}\n", Body{"// MIT", "FOR {}"}},
+		{"in reverse order", []BodyTransform{registered, license}, "for {}\n", Body{"// MIT", "FOR {}"}},
+		{"license after trim", []BodyTransform{trim, license}, "\nfor {}\n", Body{"// MIT", "for {}"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, file := newFile(t, Options{Transforms: tt.transforms}, "loop.go", tt.content)
+			if !reflect.DeepEqual(file.Body, tt.want) {
+				t.Errorf("got body %q, want %q", file.Body, tt.want)
+			}
+		})
+	}
+
+	failing := TransformFunc(func(b []byte) ([]byte, error) {
+		return nil, errors.New("no")
+	})
+	opts := Options{Transforms: []BodyTransform{failing}}
+	if _, _, err := opts.NewFile("loop.go", bytes.NewReader([]byte("for {}\n"))); err == nil {
+		t.Error("got no error from a failing transform")
+	}
+
+	names := TransformNames()
+	for _, name := range []string{"dedent", "test-upper", "trim"} {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Errorf("%s missing from %q", name, names)
+		}
+	}
+}
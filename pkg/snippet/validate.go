@@ -0,0 +1,46 @@
+package snippet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validate returns an ErrInvalid error listing the snippets of outputs VS
+// Code would not load or offer: the ones without prefix or body and, in the
+// workspace format, without scope.
+func (o *Options) validate(outputs Outputs) error {
+	var problems []string
+	for _, name := range outputs.Names() {
+		snippet := outputs[name]
+		for _, k := range snippet.Keys() {
+			for _, problem := range o.problems((*snippet)[k]) {
+				problems = append(problems, fmt.Sprintf("%s: %q %s", name, k, problem))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+}
+
+// problems returns what is wrong with file.
+func (o *Options) problems(file *File) []string {
+	var problems []string
+	if len(file.Prefix) == 0 {
+		problems = append(problems, "has no prefix")
+	}
+	for _, prefix := range file.Prefix {
+		if prefix == "" {
+			problems = append(problems, "has an empty prefix")
+			break
+		}
+	}
+	if strings.Join(file.Body, "") == "" {
+		problems = append(problems, "has an empty body")
+	}
+	if o.Format == "workspace" && file.Scope == "" {
+		problems = append(problems, "has no scope")
+	}
+	return problems
+}
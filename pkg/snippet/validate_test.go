@@ -0,0 +1,67 @@
+package snippet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProblems(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts Options
+		file File
+		want []string
+	}{
+		{"valid", Options{}, File{Prefix: Prefix{"loop"}, Body: Body{"for {}"}}, nil},
+		{"no prefix", Options{}, File{Body: Body{"for {}"}}, []string{"has no prefix"}},
+		{"empty prefix", Options{}, File{Prefix: Prefix{"loop", ""}, Body: Body{"for {}"}}, []string{"has an empty prefix"}},
+		{"empty body", Options{}, File{Prefix: Prefix{"loop"}, Body: Body{""}}, []string{"has an empty body"}},
+		{"blank lines body", Options{}, File{Prefix: Prefix{"loop"}, Body: Body{"", ""}}, []string{"has an empty body"}},
+		{"no body", Options{}, File{Prefix: Prefix{"loop"}}, []string{"has an empty body"}},
+		{"spaces body", Options{}, File{Prefix: Prefix{"loop"}, Body: Body{"  "}}, nil},
+		{"no scope", Options{}, File{Prefix: Prefix{"loop"}, Body: Body{"for {}"}}, nil},
+		{"workspace no scope", Options{Format: "workspace"}, File{Prefix: Prefix{"loop"}, Body: Body{"for {}"}}, []string{"has no scope"}},
+		{"workspace scoped", Options{Format: "workspace"}, File{Prefix: Prefix{"loop"}, Body: Body{"for {}"}, Scope: "go"}, nil},
+		{"all", Options{Format: "workspace"}, File{}, []string{"has no prefix", "has an empty body", "has no scope"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.problems(&tt.file); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	files := map[string]string{
+		"empty.go": "\n",
+		"loop.go":  "for {}\n",
+		"blank.py": "\n\n",
+	}
+	s, _ := addFiles(t, Options{}, files)
+	if _, err := s.Outputs(); err != nil {
+		t.Errorf("got error %v without Strict", err)
+	}
+
+	s, _ = addFiles(t, Options{Strict: true}, files)
+	_, err := s.Outputs()
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalid)
+	}
+	// Every offending snippet is listed, with its file.
+	for _, want := range []string{`go.json: "empty" has an empty body`, `python.json: "blank" has an empty body`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to list %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), `"loop"`) {
+		t.Errorf("got error %q listing a valid snippet", err)
+	}
+
+	s, _ = addFiles(t, Options{Strict: true}, map[string]string{"loop.go": "for {}\n"})
+	if _, err := s.Outputs(); err != nil {
+		t.Errorf("got error %v for valid snippets", err)
+	}
+}